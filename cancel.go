@@ -0,0 +1,96 @@
+package tracer
+
+import (
+	"context"
+	"errors"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// CancelOptions 取消、超时的记录方式
+// 取消（ context.Canceled 、 gRPC Canceled ）记录 tag cancelled=true ，超时（ context.DeadlineExceeded 、
+// gRPC DeadlineExceeded 、网络超时）记录 tag timeout=true ， cancelled.by 记录由谁取消： client 或 server
+type CancelOptions struct {
+	// CancelledAsError 客户端发起的取消是否记为错误（ error=true ），默认不记，客户端断开连接不再显示为错误
+	// 服务器端发起的取消（服务器自己的 deadline 、关闭服务等）总是记为错误
+	CancelledAsError bool
+	// TimeoutNotError 超时是否不记为错误，默认记为错误
+	TimeoutNotError bool
+}
+
+// 错误的分类
+const (
+	errorOther = iota
+	errorCancelled
+	errorTimeout
+)
+
+// classifyError 区分取消、超时与其他错误
+func classifyError(err error) int {
+	if errors.Is(err, context.Canceled) {
+		return errorCancelled
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return errorTimeout
+	}
+	if s, ok := status.FromError(err); ok {
+		switch s.Code() {
+		case codes.Canceled:
+			return errorCancelled
+		case codes.DeadlineExceeded:
+			return errorTimeout
+		}
+	}
+	var t interface{ Timeout() bool }
+	if errors.As(err, &t) && t.Timeout() {
+		return errorTimeout
+	}
+	return errorOther
+}
+
+// cancelledBy 判断由谁取消： ctx 为 span 所在一侧的请求 ctx
+//   - 客户端 span ： ctx 已取消（超时时为 ctx 已结束），为客户端（本服务）取消，否则为服务器端取消
+//   - 服务器端 span ： ctx 已取消（超时时为 ctx 已结束），为客户端取消（断开连接、客户端超时），
+//     否则为服务器端自己取消（例如处理函数自己设置的 deadline 到期）
+//
+// 取消时只认 context.Canceled ：请求 ctx 因 deadline 结束、错误却是取消时，不算客户端取消
+// 关闭服务时若取消了请求 ctx （例如 grpc.Server.Stop ），无法与客户端断开区分
+func cancelledBy(ctx context.Context, kind int) string {
+	if ctx == nil || ctx.Err() == nil {
+		return "server"
+	}
+	if kind == errorCancelled && ctx.Err() != context.Canceled {
+		return "server"
+	}
+	return "client"
+}
+
+// setSpanError 在 span 上记录错误，区分取消与超时
+// ctx 为 span 所在一侧的请求 ctx ，用于判断由谁取消（见 cancelledBy ），客户端、服务器端 span 的判断方式相同
+// 只有客户端发起的取消按 CancelOptions.CancelledAsError 决定是否记为错误，服务器端发起的取消总是记为错误
+func setSpanError(ctx context.Context, tracerName string, span opentracing.Span, err error) {
+	span.LogFields(log.String("event", "error"), log.String("message", err.Error()))
+	kind := classifyError(err)
+	if kind == errorOther {
+		ext.Error.Set(span, true)
+		return
+	}
+	by := cancelledBy(ctx, kind)
+	span.SetTag("cancelled.by", by)
+	o := &getOptions(tracerName).Cancel
+	if kind == errorCancelled {
+		span.SetTag("cancelled", true)
+		if by == "server" || o.CancelledAsError {
+			ext.Error.Set(span, true)
+		}
+	} else {
+		span.SetTag("timeout", true)
+		if !o.TimeoutNotError {
+			ext.Error.Set(span, true)
+		}
+	}
+}
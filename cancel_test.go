@@ -0,0 +1,61 @@
+package tracer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestSetSpanError(t *testing.T) {
+	live := context.Background()
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithDeadline(context.Background(), time.Time{})
+	defer cancel()
+
+	tests := []struct {
+		name          string
+		ctx           context.Context
+		err           error
+		asError       bool // CancelOptions.CancelledAsError
+		wantBy        interface{}
+		wantCancelled interface{}
+		wantTimeout   interface{}
+		wantError     interface{}
+	}{
+		{"other", live, errors.New("boom"), false, nil, nil, nil, true},
+		{"client cancel", cancelled, context.Canceled, false, "client", true, nil, nil},
+		{"client cancel wrapped", cancelled, fmt.Errorf("read: %w", context.Canceled), false, "client", true, nil, nil},
+		{"client cancel grpc", cancelled, status.Error(codes.Canceled, "canceled"), false, "client", true, nil, nil},
+		{"client cancel as error", cancelled, context.Canceled, true, "client", true, nil, true},
+		{"server cancel", live, context.Canceled, false, "server", true, nil, true},
+		{"server cancel grpc", live, status.Error(codes.Canceled, "canceled"), false, "server", true, nil, true},
+		{"cancel after deadline", expired, context.Canceled, false, "server", true, nil, true},
+		{"client timeout", expired, context.DeadlineExceeded, false, "client", nil, true, true},
+		{"server timeout", live, status.Error(codes.DeadlineExceeded, "deadline"), false, "server", nil, true, true},
+		{"nil ctx", nil, context.Canceled, false, "server", true, nil, true},
+	}
+	const name = "cancel_test"
+	defer SetOptions(name, Options{})
+	for _, tt := range tests {
+		SetOptions(name, Options{Cancel: CancelOptions{CancelledAsError: tt.asError}})
+		span := mocktracer.New().StartSpan("op").(*mocktracer.MockSpan)
+		setSpanError(tt.ctx, name, span, tt.err)
+		for tag, want := range map[string]interface{}{
+			"cancelled.by": tt.wantBy,
+			"cancelled":    tt.wantCancelled,
+			"timeout":      tt.wantTimeout,
+			"error":        tt.wantError,
+		} {
+			if got := span.Tag(tag); got != want {
+				t.Errorf("%s: %s = %v, want %v", tt.name, tag, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/fananchong/tracer/spans"
+)
+
+func init() {
+	commands["critical"] = &command{
+		usage: "print the critical path of each trace with self-time and contribution",
+		run:   runCritical,
+	}
+}
+
+func runCritical(args []string) error {
+	fs := newFlagSet("critical")
+	var f filter
+	f.register(fs)
+	fs.Parse(args)
+
+	traces, err := load(fs.Args())
+	if err != nil {
+		return err
+	}
+	for _, t := range f.apply(traces) {
+		if cp := t.CriticalPath(); cp != nil {
+			printCriticalPath(os.Stdout, t, cp)
+		}
+	}
+	return nil
+}
+
+func printCriticalPath(w io.Writer, t *spans.Trace, cp *spans.CriticalPath) {
+	fmt.Fprintf(w, "trace %s  %s: %s  %s\n", t.ID, cp.Root.Service, cp.Root.Operation, spans.FormatDuration(cp.Duration))
+	fmt.Fprintf(w, "%10s %7s %10s %10s  %s\n", "critical", "%", "self", "duration", "span")
+	for _, cs := range cp.Spans {
+		marker := ""
+		if cs.Span.Error() {
+			marker = "  ✗ error"
+		}
+		fmt.Fprintf(w, "%10s %6.1f%% %10s %10s  %s%s: %s%s\n",
+			spans.FormatDuration(cs.Critical), cs.Percent, spans.FormatDuration(cs.SelfTime), spans.FormatDuration(cs.Span.Duration),
+			strings.Repeat("  ", cs.Depth), cs.Span.Service, cs.Span.Operation, marker)
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/fananchong/tracer/spans"
+)
+
+func init() {
+	commands["deps"] = &command{
+		usage: "build the service dependency graph and print it as Graphviz DOT or JSON",
+		run:   runDeps,
+	}
+}
+
+func runDeps(args []string) error {
+	fs := newFlagSet("deps")
+	var f filter
+	f.register(fs)
+	format := fs.String("format", "dot", "output format: dot or json")
+	fs.Parse(args)
+
+	traces, err := load(fs.Args())
+	if err != nil {
+		return err
+	}
+	g := spans.Dependencies(f.apply(traces))
+	switch *format {
+	case "dot":
+		return g.WriteDOT(os.Stdout)
+	case "json":
+		return g.WriteJSON(os.Stdout)
+	}
+	return fmt.Errorf("unknown format %q", *format)
+}
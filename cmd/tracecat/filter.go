@@ -0,0 +1,23 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/fananchong/tracer/spans"
+)
+
+// filter trace 过滤条件，各子命令共用
+type filter struct {
+	spans.Filter
+}
+
+func (f *filter) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.Service, "service", "", "only traces with a span of this service")
+	fs.StringVar(&f.Operation, "operation", "", "only traces with a span whose operation contains this string")
+	fs.DurationVar(&f.MinDuration, "min-duration", 0, "only traces lasting at least this long")
+	fs.BoolVar(&f.Errors, "errors", false, "only traces with an error span")
+}
+
+func (f *filter) apply(traces []*spans.Trace) []*spans.Trace {
+	return f.Apply(traces)
+}
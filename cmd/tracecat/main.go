@@ -0,0 +1,90 @@
+// tracecat 在终端查看 trace
+//
+// 用法：
+//
+//	tracecat [命令] [参数] 文件...
+//
+// 文件为 jaeger UI 下载的 JSON ，或者 tracer.UseJSONLines 写入的 JSON lines 文件
+// 文件为 - 时，从标准输入读取
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/fananchong/tracer/spans"
+)
+
+// command 子命令
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]*command{}
+
+// defaultCommand 不指定子命令时执行的命令
+const defaultCommand = "tree"
+
+func main() {
+	args := os.Args[1:]
+	name := defaultCommand
+	if len(args) > 0 {
+		if _, ok := commands[args[0]]; ok {
+			name, args = args[0], args[1:]
+		} else if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+			usage()
+			return
+		}
+	}
+	if err := commands[name].run(args); err != nil {
+		fmt.Fprintf(os.Stderr, "tracecat %s: %s\n", name, err.Error())
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: tracecat [command] [flags] file...\n\ncommands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nrun 'tracecat <command> -h' for the flags of a command\n")
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("tracecat "+name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: tracecat %s [flags] file...\n\n%s\n\nflags:\n", name, commands[name].usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// load 读取所有文件中的 span ，按 trace 分组
+func load(files []string) ([]*spans.Trace, error) {
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	var all []*spans.Span
+	for _, file := range files {
+		var s []*spans.Span
+		var err error
+		if file == "-" {
+			s, err = spans.Read(os.Stdin)
+		} else {
+			s, err = spans.ReadFile(file)
+		}
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, s...)
+	}
+	return spans.Group(all), nil
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fananchong/tracer/spans"
+	"github.com/google/pprof/profile"
+)
+
+func init() {
+	commands["profile"] = &command{
+		usage: "filter a pprof profile recorded with tracer profile labels by trace, service or operation",
+		run:   runProfile,
+	}
+}
+
+// profileFilter 样本过滤条件，对应 tracer 写入的 pprof 标签
+type profileFilter struct {
+	traceID   string
+	spanID    string
+	service   string
+	operation string
+}
+
+func (f *profileFilter) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.traceID, "trace", "", "only samples of this trace id (32 hex digits)")
+	fs.StringVar(&f.spanID, "span", "", "only samples of this span id (16 hex digits)")
+	fs.StringVar(&f.service, "service", "", "only samples of this service")
+	fs.StringVar(&f.operation, "operation", "", "only samples whose operation contains this string")
+}
+
+func (f *profileFilter) match(s *profile.Sample) bool {
+	if f.traceID != "" && label(s, "trace_id") != f.traceID {
+		return false
+	}
+	if f.spanID != "" && label(s, "span_id") != f.spanID {
+		return false
+	}
+	if f.service != "" && label(s, "service") != f.service {
+		return false
+	}
+	if f.operation != "" && !strings.Contains(label(s, "operation"), f.operation) {
+		return false
+	}
+	return true
+}
+
+// label 样本的字符串标签，没有时返回空字符串
+func label(s *profile.Sample, key string) string {
+	if values := s.Label[key]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func runProfile(args []string) error {
+	fs := newFlagSet("profile")
+	var f profileFilter
+	f.register(fs)
+	by := fs.String("by", "operation", "label to group matched samples by: trace_id, span_id, service or operation")
+	top := fs.Int("top", 10, "number of functions to print")
+	index := fs.Int("sample-index", -1, "index of the sample value to report, -1 for the last one (cpu for CPU profiles)")
+	output := fs.String("o", "", "write the matched samples to this file as a profile for go tool pprof")
+	fs.Parse(args)
+
+	if fs.NArg() > 1 {
+		return errors.New("expected one profile file")
+	}
+	file := "-"
+	if fs.NArg() == 1 {
+		file = fs.Arg(0)
+	}
+	p, err := loadProfile(file)
+	if err != nil {
+		return err
+	}
+	if len(p.SampleType) == 0 {
+		return errors.New("profile has no sample types")
+	}
+	if *index < 0 {
+		*index = len(p.SampleType) - 1
+	}
+	if *index >= len(p.SampleType) {
+		return fmt.Errorf("sample index %d out of range, profile has %d sample types", *index, len(p.SampleType))
+	}
+
+	var matched []*profile.Sample
+	for _, s := range p.Sample {
+		if f.match(s) {
+			matched = append(matched, s)
+		}
+	}
+	printProfile(os.Stdout, p, matched, *index, *by, *top)
+
+	if *output != "" {
+		out, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		if err = writeProfile(out, p, matched); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	}
+	return nil
+}
+
+// loadProfile 读取 pprof profile ，支持 gzip 压缩（ runtime/pprof 的默认输出）和未压缩两种
+func loadProfile(file string) (*profile.Profile, error) {
+	if file == "-" {
+		return profile.Parse(os.Stdin)
+	}
+	r, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return profile.Parse(r)
+}
+
+// writeProfile 写出只含 keep 中样本的 profile （ gzip 压缩），其他字段不变
+func writeProfile(w io.Writer, p *profile.Profile, keep []*profile.Sample) error {
+	kept := make(map[*profile.Sample]bool, len(keep))
+	for _, s := range keep {
+		kept[s] = true
+	}
+	// Copy 保持样本顺序，按下标对应
+	filtered := p.Copy()
+	samples := filtered.Sample
+	filtered.Sample = nil
+	for i, s := range p.Sample {
+		if kept[s] {
+			filtered.Sample = append(filtered.Sample, samples[i])
+		}
+	}
+	return filtered.Write(w)
+}
+
+// stack 样本的调用栈函数名，最内层在前
+func stack(s *profile.Sample) []string {
+	var names []string
+	for _, loc := range s.Location {
+		for _, line := range loc.Line {
+			if line.Function != nil {
+				names = append(names, line.Function.Name)
+			}
+		}
+	}
+	return names
+}
+
+// printProfile 打印匹配样本的总量、按标签分组的占比、 flat/cum 最大的函数
+func printProfile(w io.Writer, p *profile.Profile, matched []*profile.Sample, index int, by string, top int) {
+	vt := p.SampleType[index]
+	value := func(s *profile.Sample) int64 {
+		if index < len(s.Value) {
+			return s.Value[index]
+		}
+		return 0
+	}
+	var total, sum int64
+	for _, s := range p.Sample {
+		total += value(s)
+	}
+	groups := map[string]int64{}
+	flat := map[string]int64{}
+	cum := map[string]int64{}
+	for _, s := range matched {
+		v := value(s)
+		sum += v
+		name := label(s, by)
+		if name == "" {
+			name = "(no " + by + " label)"
+		}
+		groups[name] += v
+		fns := stack(s)
+		if len(fns) > 0 {
+			flat[fns[0]] += v
+		}
+		seen := map[string]bool{}
+		for _, fn := range fns {
+			if !seen[fn] {
+				seen[fn] = true
+				cum[fn] += v
+			}
+		}
+	}
+
+	format := func(v int64) string {
+		if vt.Unit == "nanoseconds" {
+			return spans.FormatDuration(time.Duration(v))
+		}
+		return fmt.Sprint(v)
+	}
+	percent := func(v, of int64) float64 {
+		if of == 0 {
+			return 0
+		}
+		return float64(v) * 100 / float64(of)
+	}
+
+	fmt.Fprintf(w, "%s/%s  matched %d of %d samples  %s of %s (%.1f%%)\n\n",
+		vt.Type, vt.Unit, len(matched), len(p.Sample), format(sum), format(total), percent(sum, total))
+
+	fmt.Fprintf(w, "by %s:\n", by)
+	for _, name := range sortByValue(groups) {
+		fmt.Fprintf(w, "%10s %6.1f%%  %s\n", format(groups[name]), percent(groups[name], sum), name)
+	}
+
+	names := sortByValue(flat)
+	if len(names) > top {
+		names = names[:top]
+	}
+	fmt.Fprintf(w, "\ntop %d functions:\n%10s %7s %10s %7s  %s\n", len(names), "flat", "flat%", "cum", "cum%", "function")
+	for _, name := range names {
+		fmt.Fprintf(w, "%10s %6.1f%% %10s %6.1f%%  %s\n",
+			format(flat[name]), percent(flat[name], sum), format(cum[name]), percent(cum[name], sum), name)
+	}
+}
+
+// sortByValue 按值从大到小排序，值相同时按名字排序
+func sortByValue(m map[string]int64) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if m[names[i]] != m[names[j]] {
+			return m[names[i]] > m[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
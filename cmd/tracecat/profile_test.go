@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/pprof/profile"
+)
+
+// 样本的 trace id ， tracer 写入 32 位十六进制
+const (
+	testTraceID1 = "00000000000000000000000000000a1b"
+	testTraceID2 = "000000000000000000000000000000ff"
+)
+
+// testProfile 两个 trace 的 CPU profile ，样本带 tracer 写入的标签
+func testProfile() *profile.Profile {
+	handle := &profile.Function{ID: 1, Name: "main.handle"}
+	query := &profile.Function{ID: 2, Name: "main.query"}
+	locHandle := &profile.Location{ID: 1, Line: []profile.Line{{Function: handle}}}
+	locQuery := &profile.Location{ID: 2, Line: []profile.Line{{Function: query}}}
+	labels := func(traceID, operation string) map[string][]string {
+		return map[string][]string{"trace_id": {traceID}, "service": {"server1"}, "operation": {operation}}
+	}
+	return &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "samples", Unit: "count"}, {Type: "cpu", Unit: "nanoseconds"}},
+		PeriodType: &profile.ValueType{Type: "cpu", Unit: "nanoseconds"},
+		Period:     10000000,
+		Sample: []*profile.Sample{
+			{Location: []*profile.Location{locQuery, locHandle}, Value: []int64{3, 30000000}, Label: labels(testTraceID1, "/test1")},
+			{Location: []*profile.Location{locHandle}, Value: []int64{1, 10000000}, Label: labels(testTraceID1, "/test1")},
+			{Location: []*profile.Location{locHandle}, Value: []int64{2, 20000000}, Label: labels(testTraceID2, "/test2")},
+			{Location: []*profile.Location{locHandle}, Value: []int64{4, 40000000}},
+		},
+		Location: []*profile.Location{locHandle, locQuery},
+		Function: []*profile.Function{handle, query},
+	}
+}
+
+// TestProfileRoundTrip 读取、按 trace 过滤、写出后， go tool pprof 能读取，样本、标签、调用栈不变
+func TestProfileRoundTrip(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cpu.pprof")
+	out, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = testProfile().Write(out); err != nil {
+		t.Fatal(err)
+	}
+	out.Close()
+
+	p, err := loadProfile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := profileFilter{traceID: testTraceID1}
+	var matched []*profile.Sample
+	for _, s := range p.Sample {
+		if f.match(s) {
+			matched = append(matched, s)
+		}
+	}
+	if len(matched) != 2 {
+		t.Fatalf("matched %d samples, want 2", len(matched))
+	}
+
+	var buf bytes.Buffer
+	if err = writeProfile(&buf, p, matched); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Sample) != 4 {
+		t.Errorf("writeProfile modified the input profile: %d samples", len(p.Sample))
+	}
+	filtered, err := profile.Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = filtered.CheckValid(); err != nil {
+		t.Fatal(err)
+	}
+	if len(filtered.Sample) != 2 {
+		t.Fatalf("got %d samples, want 2", len(filtered.Sample))
+	}
+	s := filtered.Sample[0]
+	if label(s, "trace_id") != testTraceID1 || label(s, "operation") != "/test1" || s.Value[1] != 30000000 {
+		t.Errorf("got sample %v %v", s.Label, s.Value)
+	}
+	if got := strings.Join(stack(s), " "); got != "main.query main.handle" {
+		t.Errorf("got stack %q", got)
+	}
+	if filtered.Period != p.Period || filtered.SampleType[1].Unit != "nanoseconds" {
+		t.Errorf("profile fields changed: period %d, sample types %v", filtered.Period, filtered.SampleType)
+	}
+}
+
+func TestPrintProfile(t *testing.T) {
+	p := testProfile()
+	var buf bytes.Buffer
+	printProfile(&buf, p, p.Sample[:3], 1, "operation", 10)
+	got := buf.String()
+	for _, want := range []string{
+		"cpu/nanoseconds  matched 3 of 4 samples  60.00ms of 100.00ms (60.0%)",
+		"40.00ms   66.7%  /test1",
+		"20.00ms   33.3%  /test2",
+		"30.00ms   50.0%    60.00ms  100.0%  main.handle",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output does not contain %q:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fananchong/tracer/spans"
+)
+
+func init() {
+	commands["tree"] = &command{
+		usage: "print each trace as an indented span tree with a timeline (default command)",
+		run:   runTree,
+	}
+}
+
+func runTree(args []string) error {
+	fs := newFlagSet("tree")
+	var f filter
+	f.register(fs)
+	width := fs.Int("width", 40, "width of the timeline bar")
+	fs.Parse(args)
+
+	traces, err := load(fs.Args())
+	if err != nil {
+		return err
+	}
+	for _, t := range f.apply(traces) {
+		printTree(os.Stdout, t, *width)
+	}
+	return nil
+}
+
+func printTree(w io.Writer, t *spans.Trace, width int) {
+	status := ""
+	if t.HasError() {
+		status = "  ERROR"
+	}
+	fmt.Fprintf(w, "trace %s  %s  %d spans  %s%s\n",
+		t.ID, t.Start().Format("2006-01-02 15:04:05.000"), len(t.Spans), spans.FormatDuration(t.Duration()), status)
+	p := &treePrinter{w: w, width: width, start: t.Start(), total: t.Duration()}
+	for _, root := range t.Tree() {
+		p.print(root, "", "")
+	}
+	fmt.Fprintln(w)
+}
+
+type treePrinter struct {
+	w     io.Writer
+	width int
+	start time.Time
+	total time.Duration
+}
+
+func (p *treePrinter) print(n *spans.Node, prefix, childPrefix string) {
+	s := n.Span
+	marker := ""
+	if s.Error() {
+		marker = "  ✗ error"
+	}
+	fmt.Fprintf(p.w, "%s %10s  %s%s: %s%s\n",
+		p.bar(s), spans.FormatDuration(s.Duration), prefix, s.Service, s.Operation, marker)
+	for i, c := range n.Children {
+		if i == len(n.Children)-1 {
+			p.print(c, childPrefix+"└─ ", childPrefix+"   ")
+		} else {
+			p.print(c, childPrefix+"├─ ", childPrefix+"│  ")
+		}
+	}
+}
+
+// bar 时间轴，标出 span 在 trace 中的起止位置
+func (p *treePrinter) bar(s *spans.Span) string {
+	if p.width <= 0 {
+		return ""
+	}
+	from, to := 0, p.width
+	if p.total > 0 {
+		from = int(int64(s.Start.Sub(p.start)) * int64(p.width) / int64(p.total))
+		to = int(int64(s.End().Sub(p.start)) * int64(p.width) / int64(p.total))
+	}
+	if from < 0 {
+		from = 0
+	}
+	if from >= p.width {
+		from = p.width - 1
+	}
+	if to <= from {
+		to = from + 1
+	}
+	if to > p.width {
+		to = p.width
+	}
+	return "|" + strings.Repeat(" ", from) + strings.Repeat("=", to-from) + strings.Repeat(" ", p.width-to) + "|"
+}
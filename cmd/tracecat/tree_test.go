@@ -0,0 +1,29 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fananchong/tracer/spans"
+)
+
+// TestPrintTreeMalformed span id 重复、父子关系成环时，每个 span 只打印一次
+func TestPrintTreeMalformed(t *testing.T) {
+	start := time.Unix(0, 0)
+	trace := &spans.Trace{ID: "1", Spans: []*spans.Span{
+		{SpanID: "1", ParentID: "2", Service: "s", Operation: "a", Start: start, Duration: time.Millisecond},
+		{SpanID: "2", ParentID: "1", Service: "s", Operation: "b", Start: start.Add(time.Microsecond), Duration: time.Microsecond},
+		{SpanID: "2", ParentID: "1", Service: "s", Operation: "c", Start: start.Add(2 * time.Microsecond), Duration: time.Microsecond},
+		{SpanID: "3", ParentID: "2", Service: "s", Operation: "d", Start: start.Add(3 * time.Microsecond), Duration: time.Microsecond},
+	}}
+	var buf bytes.Buffer
+	printTree(&buf, trace, 0)
+	out := buf.String()
+	for _, op := range []string{"s: a", "s: b", "s: c", "s: d"} {
+		if n := strings.Count(out, op+"\n"); n != 1 {
+			t.Errorf("%q printed %d times\n%s", op, n, out)
+		}
+	}
+}
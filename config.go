@@ -0,0 +1,202 @@
+package tracer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/grpclog"
+)
+
+// Config 动态配置文件（ JSON ）格式
+//
+//	{
+//	  "tracers": [
+//	    {"name": "server1", "enabled": true, "sampling": 0.1, "skip": ["/health", "/grpc.health.v1.Health/*"]},
+//	    {"name": "server2", "enabled": true, "payload": false},
+//	    {"name": "server3", "enabled": false}
+//	  ]
+//	}
+type Config struct {
+	Tracers []TracerConfig `json:"tracers"`
+}
+
+// TracerConfig 一个 tracer 的配置
+type TracerConfig struct {
+	// Name tracer 名字
+	Name string `json:"name"`
+	// Enabled 是否打开
+	Enabled bool `json:"enabled"`
+	// Sampling 采样率， 0 ~ 1 ，不填时不修改当前的采样率
+	Sampling *float64 `json:"sampling,omitempty"`
+	// Payload 是否记录 gRPC 请求、应答内容，不填为 true
+	Payload *bool `json:"payload,omitempty"`
+	// Skip 不追踪的操作，同 Options.Skip
+	Skip []string `json:"skip,omitempty"`
+}
+
+// ParseConfig 解析、检查配置
+func ParseConfig(data []byte) (*Config, error) {
+	var c Config
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.DisallowUnknownFields()
+	if err := d.Decode(&c); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Validate 检查配置
+func (c *Config) Validate() error {
+	var errs []string
+	names := map[string]bool{}
+	for i, t := range c.Tracers {
+		switch {
+		case t.Name == "":
+			errs = append(errs, fmt.Sprintf("tracers[%d]: name is empty", i))
+		case names[t.Name]:
+			errs = append(errs, fmt.Sprintf("tracers[%d]: duplicate name %q", i, t.Name))
+		}
+		names[t.Name] = true
+		if t.Sampling != nil && (*t.Sampling < 0 || *t.Sampling > 1) {
+			errs = append(errs, fmt.Sprintf("tracers[%d]: sampling %v out of range [0, 1]", i, *t.Sampling))
+		}
+		for _, pattern := range t.Skip {
+			if pattern == "" || pattern == "*" {
+				errs = append(errs, fmt.Sprintf("tracers[%d]: invalid skip pattern %q", i, pattern))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// ApplyConfig 应用配置：打开、关闭 tracer ，设置采样率，更新集成选项（其他选项保持不变）
+// 不取消 EnableFor 设置的自动关闭，重新加载配置不会让临时打开的 tracer 一直打开
+// 配置中没有的 tracer 不受影响：从配置中删除的 tracer 保持最后一次应用的状态、采样率、选项，
+// 需要恢复时在配置中写明（例如 "enabled": false ）
+func ApplyConfig(c *Config) error {
+	var errs []string
+	for _, t := range c.Tracers {
+		if !t.Enabled {
+			DefaultTracer.Disable(t.Name)
+		} else if err := DefaultTracer.Enable(t.Name); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", t.Name, err.Error()))
+			continue
+		}
+		if t.Sampling != nil {
+			if err := SetSamplingRate(t.Name, *t.Sampling); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %s", t.Name, err.Error()))
+			}
+		}
+		o := GetOptions(t.Name)
+		o.GRPC.NoPayload = t.Payload != nil && !*t.Payload
+		o.Skip = t.Skip
+		SetOptions(t.Name, o)
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// samplingRateSetter ITracer 可选实现的接口，动态设置采样率
+type samplingRateSetter interface {
+	SetSamplingRate(name string, rate float64) error
+}
+
+// SetSamplingRate 设置 tracer 的采样率（ 0 ~ 1 ），立即生效
+func SetSamplingRate(name string, rate float64) error {
+	s, ok := DefaultTracer.(samplingRateSetter)
+	if !ok {
+		return errors.New("tracer does not support sampling rate")
+	}
+	return s.SetSamplingRate(name, rate)
+}
+
+// ConfigWatcher 定时检查配置文件，文件内容变化时重新加载
+type ConfigWatcher struct {
+	path       string
+	onError    func(error)
+	last       []byte // 最近一次读取的文件内容
+	readFailed bool
+	stop       chan struct{}
+	once       sync.Once
+}
+
+// WatchConfig 加载配置文件，之后每隔 interval 检查一次，文件内容变化时重新加载
+// 配置文件读取失败、格式错误、检查不通过时，调用 onError （为 nil 时通过 grpclog 输出错误），继续使用当前的配置
+// 首次加载的错误同时返回，此时仍然会继续检查（修正文件后生效）
+func WatchConfig(path string, interval time.Duration, onError func(error)) (*ConfigWatcher, error) {
+	if onError == nil {
+		onError = func(err error) {
+			grpclog.Errorf("tracer config error! %s", err.Error())
+		}
+	}
+	w := &ConfigWatcher{path: path, onError: onError, stop: make(chan struct{})}
+	err := w.reload()
+	if err != nil {
+		onError(err)
+	}
+	go w.run(interval)
+	return w, err
+}
+
+func (w *ConfigWatcher) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := w.reload(); err != nil {
+				w.onError(err)
+			}
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// reload 文件内容变化时重新加载。同样的错误内容只报告一次
+func (w *ConfigWatcher) reload() error {
+	data, err := ioutil.ReadFile(w.path)
+	if err != nil {
+		if w.readFailed {
+			return nil
+		}
+		w.readFailed = true
+		return fmt.Errorf("%s: %s", w.path, err.Error())
+	}
+	w.readFailed = false
+	if len(data) == 0 {
+		// 文件正在写入（先清空再写入），下次再检查
+		return nil
+	}
+	if w.last != nil && bytes.Equal(data, w.last) {
+		return nil
+	}
+	w.last = data
+	c, err := ParseConfig(data)
+	if err != nil {
+		return fmt.Errorf("%s: %s", w.path, err.Error())
+	}
+	if err = ApplyConfig(c); err != nil {
+		return fmt.Errorf("%s: %s", w.path, err.Error())
+	}
+	return nil
+}
+
+// Stop 停止检查
+func (w *ConfigWatcher) Stop() {
+	w.once.Do(func() { close(w.stop) })
+}
@@ -0,0 +1,78 @@
+package tracer_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fananchong/tracer"
+	"github.com/fananchong/tracer/tracetest"
+)
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		data string
+		err  string // 为空表示没有错误
+	}{
+		{`{"tracers": [{"name": "a", "enabled": true, "sampling": 0.5, "payload": false, "skip": ["/health", "/grpc.health.v1.Health/*"]}]}`, ""},
+		{`{"tracers": []}`, ""},
+		{`{"tracers": [{"name": "a"}`, "unexpected EOF"},
+		{`{"tracers": [{"name": "a", "sample": 0.5}]}`, `unknown field "sample"`},
+		{`{"tracers": [{"enabled": true}]}`, "tracers[0]: name is empty"},
+		{`{"tracers": [{"name": "a"}, {"name": "a"}]}`, `tracers[1]: duplicate name "a"`},
+		{`{"tracers": [{"name": "a", "sampling": 1.5}]}`, "tracers[0]: sampling 1.5 out of range [0, 1]"},
+		{`{"tracers": [{"name": "a", "sampling": -0.1}]}`, "tracers[0]: sampling -0.1 out of range [0, 1]"},
+		{`{"tracers": [{"name": "a", "skip": ["*"]}]}`, `tracers[0]: invalid skip pattern "*"`},
+		{`{"tracers": [{"name": "a", "skip": [""]}, {"name": ""}]}`, `tracers[0]: invalid skip pattern ""; tracers[1]: name is empty`},
+	}
+	for _, tt := range tests {
+		_, err := tracer.ParseConfig([]byte(tt.data))
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("ParseConfig(%s) = %v, want %q", tt.data, err, tt.err)
+		}
+	}
+}
+
+// TestApplyConfigKeepsSampling 没有配置 sampling 时，保持当前的采样率
+func TestApplyConfigKeepsSampling(t *testing.T) {
+	rec := tracetest.Start(t, "config1")
+	if err := tracer.SetSamplingRate("config1", 0); err != nil {
+		t.Fatal(err)
+	}
+	c, err := tracer.ParseConfig([]byte(`{"tracers": [{"name": "config1", "enabled": true, "skip": ["/health"]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tracer.ApplyConfig(c); err != nil {
+		t.Fatal(err)
+	}
+	tracer.Get("config1").StartSpan("op").Finish()
+	if n := len(rec.Spans()); n != 0 {
+		t.Errorf("got %d spans, sampling rate was reset", n)
+	}
+	if o := tracer.GetOptions("config1"); len(o.Skip) != 1 || o.Skip[0] != "/health" {
+		t.Errorf("got skip %v", o.Skip)
+	}
+}
+
+// TestApplyConfigKeepsEnableFor 重新加载配置不取消 EnableFor 设置的自动关闭
+func TestApplyConfigKeepsEnableFor(t *testing.T) {
+	tracetest.Start(t)
+	if err := tracer.EnableFor("config2", 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	c, err := tracer.ParseConfig([]byte(`{"tracers": [{"name": "config2", "enabled": true}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tracer.ApplyConfig(c); err != nil {
+		t.Fatal(err)
+	}
+	if tracer.Get("config2") == nil {
+		t.Fatal("tracer not enabled")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if tracer.Get("config2") != nil {
+		t.Error("EnableFor timer was cancelled by ApplyConfig")
+	}
+}
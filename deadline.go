@@ -0,0 +1,90 @@
+package tracer
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+)
+
+// 超时预算的追踪
+// 客户端、服务器端 span 记录 ctx 的剩余时间（ deadline.remaining_ms ）
+// 调用方把自己的预算（收到请求时的剩余时间）随请求发给被调方；被调方开始处理时，
+// 剩余时间不足调用方预算的 DeadlineOptions.LowBudgetFraction 时，标记 deadline.low_budget=true
+// gRPC 自带 deadline 传递； HTTP 没有，由 HTTPTransport 发送 X-Deadline-Ms ，
+// 服务器端中间件在 DeadlineOptions.AcceptHeader 打开时据此设置 ctx 的 deadline
+
+const (
+	// DeadlineHeader HTTP 请求的剩余时间（毫秒）
+	DeadlineHeader = "X-Deadline-Ms"
+	// CallerBudgetHeader 调用方的预算（毫秒）， gRPC metadata 中为小写
+	CallerBudgetHeader = "X-Caller-Budget-Ms"
+)
+
+// DeadlineOptions 超时预算选项
+type DeadlineOptions struct {
+	// LowBudgetFraction 被调方的剩余时间低于调用方预算的该比例时，标记 deadline.low_budget ，为 0 时不标记
+	LowBudgetFraction float64
+	// AcceptHeader HTTP 服务器端是否按请求头 X-Deadline-Ms 设置 ctx 的 deadline ，默认不设置
+	// 请求头由调用方控制，只在信任调用方时打开；不大于 0 的值忽略
+	AcceptHeader bool
+	// MaxHeader X-Deadline-Ms 的上限，超过时按上限设置，为 0 时不限制
+	MaxHeader time.Duration
+}
+
+type budgetKey struct{}
+
+// budget 本服务的预算，只对 deadline 为 deadline 的 ctx 有效
+type budget struct {
+	deadline time.Time
+	left     time.Duration
+}
+
+// remaining ctx 的剩余时间
+func remaining(ctx context.Context) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	return time.Until(deadline), true
+}
+
+// clientBudget 在客户端 span 上记录剩余时间，返回要发给被调方的预算
+// 预算为本服务收到请求时的剩余时间；未知、或 ctx 的 deadline 已被修改（例如 context.WithTimeout ）时，
+// 取调用时 ctx 的剩余时间
+func clientBudget(ctx context.Context, span opentracing.Span) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	left := time.Until(deadline)
+	span.SetTag("deadline.remaining_ms", left.Milliseconds())
+	if b, found := ctx.Value(budgetKey{}).(budget); found && b.deadline.Equal(deadline) {
+		return b.left, true
+	}
+	return left, true
+}
+
+// serverBudget 在服务器端 span 上记录剩余时间、调用方预算，返回保存了本服务预算的 ctx
+// callerBudget 为调用方请求中的预算，没有时为空字符串
+func serverBudget(ctx context.Context, tracerName string, span opentracing.Span, callerBudget string) context.Context {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return ctx
+	}
+	left := time.Until(deadline)
+	span.SetTag("deadline.remaining_ms", left.Milliseconds())
+	if ms, err := strconv.ParseInt(callerBudget, 10, 64); err == nil && ms > 0 {
+		span.SetTag("deadline.caller_budget_ms", ms)
+		fraction := getOptions(tracerName).Deadline.LowBudgetFraction
+		if fraction > 0 && float64(left) < fraction*float64(time.Duration(ms)*time.Millisecond) {
+			span.SetTag("deadline.low_budget", true)
+		}
+	}
+	return context.WithValue(ctx, budgetKey{}, budget{deadline: deadline, left: left})
+}
+
+func formatMillis(d time.Duration) string {
+	return strconv.FormatInt(d.Milliseconds(), 10)
+}
@@ -0,0 +1,103 @@
+package tracer
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// autoDisable EnableFor 设置的自动关闭定时器，按 tracer 名字保存
+var autoDisable struct {
+	sync.Mutex
+	timers map[string]*time.Timer
+}
+
+// EnableFor 打开 tracer ， d 时间后自动关闭，用于生产环境临时排查问题
+// 时间到之前再次调用 EnableFor 重新计时；调用 Enable 、 Disable 取消自动关闭
+// 打开失败时取消之前的自动关闭并关闭 tracer ，不会留下一直打开的 tracer
+func EnableFor(name string, d time.Duration) error {
+	autoDisable.Lock()
+	defer autoDisable.Unlock()
+	if t, ok := autoDisable.timers[name]; ok {
+		t.Stop()
+		delete(autoDisable.timers, name)
+	}
+	if err := DefaultTracer.Enable(name); err != nil {
+		DefaultTracer.Disable(name)
+		return err
+	}
+	if autoDisable.timers == nil {
+		autoDisable.timers = map[string]*time.Timer{}
+	}
+	var t *time.Timer
+	t = time.AfterFunc(d, func() {
+		autoDisable.Lock()
+		defer autoDisable.Unlock()
+		// 已经重新计时或取消
+		if autoDisable.timers[name] != t {
+			return
+		}
+		delete(autoDisable.timers, name)
+		DefaultTracer.Disable(name)
+	})
+	autoDisable.timers[name] = t
+	return nil
+}
+
+// cancelAutoDisable 取消 EnableFor 设置的自动关闭
+func cancelAutoDisable(name string) {
+	autoDisable.Lock()
+	defer autoDisable.Unlock()
+	if t, ok := autoDisable.timers[name]; ok {
+		t.Stop()
+		delete(autoDisable.timers, name)
+	}
+}
+
+// tracerNames ITracer 可选实现的接口，获取所有创建过的 tracer 名字
+type tracerNames interface {
+	Names() []string
+}
+
+// registered Register 登记的 tracer 名字
+var registered struct {
+	sync.Mutex
+	names map[string]bool
+}
+
+// Register 登记 tracer 名字，还没有创建（调用 Enable ）的 tracer 也会出现在 Names 中，
+// 例如启动时关闭、之后通过信号（ HandleSignals ）临时打开的 tracer
+func Register(names ...string) {
+	registered.Lock()
+	defer registered.Unlock()
+	if registered.names == nil {
+		registered.names = map[string]bool{}
+	}
+	for _, name := range names {
+		registered.names[name] = true
+	}
+}
+
+// Names 获取所有登记过（ Register ）、创建过（调用过 Enable ）的 tracer 名字，包括已经关闭的，按名字排序
+func Names() []string {
+	seen := map[string]bool{}
+	registered.Lock()
+	for name := range registered.names {
+		seen[name] = true
+	}
+	registered.Unlock()
+	if n, ok := DefaultTracer.(tracerNames); ok {
+		for _, name := range n.Names() {
+			seen[name] = true
+		}
+	}
+	if len(seen) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,110 @@
+package tracer_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fananchong/tracer"
+	"github.com/fananchong/tracer/tracetest"
+)
+
+// TestEnableForRearm 时间到之前再次调用 EnableFor 重新计时
+func TestEnableForRearm(t *testing.T) {
+	tracetest.Start(t)
+	if err := tracer.EnableFor("enable1", 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := tracer.EnableFor("enable1", 150*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(80 * time.Millisecond)
+	if tracer.Get("enable1") == nil {
+		t.Fatal("disabled by the first timer")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if tracer.Get("enable1") != nil {
+		t.Error("not disabled after the second window")
+	}
+}
+
+// TestEnableForCancel Enable 、 Disable 取消自动关闭
+func TestEnableForCancel(t *testing.T) {
+	tracetest.Start(t)
+	if err := tracer.EnableFor("enable2", 30*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := tracer.Enable("enable2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tracer.EnableFor("enable3", 30*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	tracer.Disable("enable3")
+	time.Sleep(60 * time.Millisecond)
+	if tracer.Get("enable2") == nil {
+		t.Error("Enable did not cancel the timer")
+	}
+	// 取消后再次打开，不会被旧的定时器关闭
+	if err := tracer.Enable("enable3"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if tracer.Get("enable3") == nil {
+		t.Error("Disable did not cancel the timer")
+	}
+}
+
+// failingTracer 打开失败的 ITracer
+type failingTracer struct {
+	tracer.ITracer
+	fail bool
+}
+
+func (f *failingTracer) Enable(name string) error {
+	if f.fail {
+		return errors.New("enable failed")
+	}
+	return f.ITracer.Enable(name)
+}
+
+// TestEnableForError 打开失败时，取消之前的自动关闭并关闭 tracer
+func TestEnableForError(t *testing.T) {
+	tracetest.Start(t)
+	f := &failingTracer{ITracer: tracer.DefaultTracer}
+	tracer.DefaultTracer = f
+	if err := tracer.EnableFor("enable4", 30*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	f.fail = true
+	if err := tracer.EnableFor("enable4", time.Hour); err == nil {
+		t.Fatal("want error")
+	}
+	if tracer.Get("enable4") != nil {
+		t.Error("tracer left enabled after EnableFor failed")
+	}
+	// 之前的定时器已取消，不会关闭之后打开的 tracer
+	f.fail = false
+	if err := tracer.Enable("enable4"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if tracer.Get("enable4") == nil {
+		t.Error("disabled by the cancelled timer")
+	}
+}
+
+func TestNames(t *testing.T) {
+	tracetest.Start(t, "names2", "names1")
+	tracer.Register("names3", "names1")
+	got := map[string]bool{}
+	for _, name := range tracer.Names() {
+		got[name] = true
+	}
+	for _, name := range []string{"names1", "names2", "names3"} {
+		if !got[name] {
+			t.Errorf("Names() does not contain %s: %v", name, tracer.Names())
+		}
+	}
+}
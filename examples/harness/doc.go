@@ -0,0 +1,6 @@
+// Package harness 进程内运行 examples/server1..3 的拓扑，逐个执行 README 中的演练情景，检查上报的 span
+// 不需要 Redis 、 MySQL 、 jaeger agent ，可以在 CI 中执行：
+//
+//	go test ./examples/harness
+//	go test -v ./examples/harness   # 打印每个情景的 span 树
+package harness
@@ -0,0 +1,30 @@
+package harness
+
+import (
+	"testing"
+
+	"github.com/fananchong/tracer/tracetest"
+)
+
+// TestScenarios 逐个执行演练情景，每个情景使用新的拓扑，检查产生的 span
+// go test -v 时打印每个情景的 span 树
+func TestScenarios(t *testing.T) {
+	for _, sc := range scenarios {
+		sc := sc
+		t.Run(sc.name, func(t *testing.T) {
+			top := newTopology(t)
+			if sc.setup != nil {
+				sc.setup(top)
+			}
+			err := sc.run(top)
+			traces := top.traces()
+			t.Log("\n" + tracetest.Tree(traces))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = sc.check(traces); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package harness
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// fakeRedis 内存中的 Redis ，实现 RESP 协议的 AUTH/PING/SET/GET/DEL
+// 通过 redis.Options.Dialer 连接， fail 打开时所有命令返回错误
+type fakeRedis struct {
+	mu   sync.Mutex
+	data map[string]string
+	fail int32
+}
+
+func newFakeRedis() *fakeRedis {
+	return &fakeRedis{data: make(map[string]string)}
+}
+
+func (r *fakeRedis) setFail(fail bool) {
+	var v int32
+	if fail {
+		v = 1
+	}
+	atomic.StoreInt32(&r.fail, v)
+}
+
+// dial 实现 redis.Options.Dialer
+func (r *fakeRedis) dial() (net.Conn, error) {
+	client, server := net.Pipe()
+	go r.serve(server)
+	return client, nil
+}
+
+func (r *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(rd)
+		if err != nil {
+			return
+		}
+		if _, err = io.WriteString(conn, r.exec(args)); err != nil {
+			return
+		}
+	}
+}
+
+func (r *fakeRedis) exec(args []string) string {
+	if len(args) == 0 {
+		return "-ERR empty command\r\n"
+	}
+	cmd := strings.ToUpper(args[0])
+	if cmd != "AUTH" && atomic.LoadInt32(&r.fail) == 1 {
+		return "-ERR harness: redis failure\r\n"
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch {
+	case cmd == "AUTH" || cmd == "SELECT":
+		return "+OK\r\n"
+	case cmd == "PING":
+		return "+PONG\r\n"
+	case cmd == "SET" && len(args) >= 3:
+		r.data[args[1]] = args[2]
+		return "+OK\r\n"
+	case cmd == "GET" && len(args) == 2:
+		v, ok := r.data[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+	case cmd == "DEL":
+		n := 0
+		for _, k := range args[1:] {
+			if _, ok := r.data[k]; ok {
+				delete(r.data, k)
+				n++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", n)
+	}
+	return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+}
+
+// readCommand 读取一个 RESP 数组形式的命令
+func readCommand(rd *bufio.Reader) ([]string, error) {
+	line, err := readLine(rd)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		return nil, fmt.Errorf("unexpected %q", line)
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		if line, err = readLine(rd); err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, fmt.Errorf("unexpected %q", line)
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err = io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+func readLine(rd *bufio.Reader) (string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,224 @@
+package harness
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fananchong/tracer/spans"
+)
+
+// scenario 一个演练情景
+type scenario struct {
+	name   string
+	setup  func(t *topology)
+	path   string            // 请求 server1 的路径
+	header map[string]string // 请求头
+	check  func(traces []*spans.Trace) error
+}
+
+func (sc *scenario) run(t *topology) error {
+	status, body, err := t.get(sc.path, sc.header)
+	if err != nil {
+		return err
+	}
+	if status >= 500 && !strings.HasPrefix(sc.path, "/error") {
+		return fmt.Errorf("GET %s: %d %s", sc.path, status, body)
+	}
+	return nil
+}
+
+var scenarios = []*scenario{
+	{
+		name: "normal",
+		path: "/test1",
+		check: all(
+			oneTrace,
+			has("server1", "HTTP GET /test1", false),
+			has("server1", "/proto.Echo/UnaryEcho", false),
+			has("server2", "/proto.Echo/UnaryEcho", false),
+			has("server3", "/proto.Echo/TestRedis", false),
+			has("server3", "SET", false),
+			has("server3", "GET", false),
+			has("server3", "/proto.Echo/TestMySQL", false),
+			has("server3", "SQL PING", false),
+			has("server3", "SQL EXEC", false),
+			noErrors,
+		),
+	},
+	{
+		name: "gRPC streaming",
+		path: "/test2",
+		check: all(
+			oneTrace,
+			has("server2", "/proto.Echo/ServerStreamingEcho", false),
+			noErrors,
+		),
+	},
+	{
+		name: "HTTP panic",
+		path: "/error1",
+		check: all(
+			oneTrace,
+			has("server1", "HTTP GET /error1", true),
+		),
+	},
+	{
+		name: "gRPC panic",
+		path: "/error2",
+		check: all(
+			oneTrace,
+			has("server1", "/proto.Echo/UnaryEcho", true),
+			has("server2", "/proto.Echo/UnaryEcho", true),
+		),
+	},
+	{
+		name:  "MySQL failure",
+		setup: func(t *topology) { t.sql.setFail(true) },
+		path:  "/test1",
+		check: all(
+			oneTrace,
+			has("server3", "SQL PING", true),
+			has("server3", "/proto.Echo/TestMySQL", true),
+			has("server3", "GET", false),
+		),
+	},
+	{
+		name:  "Redis failure",
+		setup: func(t *topology) { t.redis.setFail(true) },
+		path:  "/test1",
+		check: all(
+			oneTrace,
+			has("server3", "SET", true),
+			has("server3", "/proto.Echo/TestRedis", true),
+			has("server3", "SQL PING", false),
+		),
+	},
+	{
+		name:  "fault injection",
+		setup: func(t *topology) { t.allowFaults(true) },
+		path:  "/test1",
+		// jaeger 格式的 baggage 请求头： uberctx-<key>
+		header: map[string]string{"uberctx-x-fault": "redis:GET:error;grpc:/proto.Echo/TestMySQL:error=drill"},
+		check: all(
+			oneTrace,
+			has("server3", "SET", false),
+			has("server3", "GET", true),
+			has("server2", "/proto.Echo/TestMySQL", true),
+			tagged("server3", "GET", "fault.injected"),
+			tagged("server2", "/proto.Echo/TestMySQL", "fault.injected"),
+		),
+	},
+	{
+		name:   "fault injection not allowed",
+		path:   "/test1",
+		header: map[string]string{"uberctx-x-fault": "redis:error"},
+		check:  all(oneTrace, noErrors),
+	},
+	{
+		name:  "call loop",
+		setup: func(t *topology) { t.loop = 3 },
+		path:  "/test1",
+		check: all(
+			oneTrace,
+			count("server2", "/proto.Echo/UnaryEcho", 4),
+			cycle("server3", "server2"),
+		),
+	},
+}
+
+func all(checks ...func([]*spans.Trace) error) func([]*spans.Trace) error {
+	return func(traces []*spans.Trace) error {
+		for _, check := range checks {
+			if err := check(traces); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// oneTrace 所有 span 属于同一个 trace ，即 span context 在各服务间正确传递
+func oneTrace(traces []*spans.Trace) error {
+	if len(traces) != 1 {
+		return fmt.Errorf("want 1 trace, got %d", len(traces))
+	}
+	if roots := traces[0].Tree(); len(roots) != 1 {
+		return fmt.Errorf("want 1 root span, got %d", len(roots))
+	}
+	return nil
+}
+
+func noErrors(traces []*spans.Trace) error {
+	for _, t := range traces {
+		for _, s := range t.Spans {
+			if s.Error() {
+				return fmt.Errorf("unexpected error span %s: %s", s.Service, s.Operation)
+			}
+		}
+	}
+	return nil
+}
+
+// has 存在 service 的 operation span ，且是否出错与 wantErr 一致
+func has(service, operation string, wantErr bool) func([]*spans.Trace) error {
+	return func(traces []*spans.Trace) error {
+		found := false
+		for _, t := range traces {
+			for _, s := range t.Spans {
+				if s.Service == service && s.Operation == operation {
+					if s.Error() == wantErr {
+						return nil
+					}
+					found = true
+				}
+			}
+		}
+		if found {
+			return fmt.Errorf("span %s: %s: want error=%v", service, operation, wantErr)
+		}
+		return fmt.Errorf("missing span %s: %s", service, operation)
+	}
+}
+
+func count(service, operation string, want int) func([]*spans.Trace) error {
+	return func(traces []*spans.Trace) error {
+		n := 0
+		for _, t := range traces {
+			for _, s := range t.Spans {
+				if s.Service == service && s.Operation == operation {
+					n++
+				}
+			}
+		}
+		if n != want {
+			return fmt.Errorf("want %d spans %s: %s, got %d", want, service, operation, n)
+		}
+		return nil
+	}
+}
+
+// tagged service 的 operation span 带有 tag
+func tagged(service, operation, tag string) func([]*spans.Trace) error {
+	return func(traces []*spans.Trace) error {
+		for _, t := range traces {
+			for _, s := range t.Spans {
+				if s.Service == service && s.Operation == operation && s.Tag(tag) != "" {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("span %s: %s: missing tag %s", service, operation, tag)
+	}
+}
+
+// cycle 依赖图中 from -> to 的边在调用环上
+func cycle(from, to string) func([]*spans.Trace) error {
+	return func(traces []*spans.Trace) error {
+		for _, e := range spans.Dependencies(traces).Edges {
+			if e.From == from && e.To == to && e.Cycle {
+				return nil
+			}
+		}
+		return fmt.Errorf("no call loop %s -> %s in the dependency graph", from, to)
+	}
+}
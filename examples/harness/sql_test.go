@@ -0,0 +1,58 @@
+package harness
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// mockSQL 用 sqlmock 代替 MySQL ，只支持 TestMySQL 的 Ping 与 Exec
+// 通过 tracer.OpenDB 包装 sqlmock 的驱动， fail 打开时 Ping 返回错误
+type mockSQL struct {
+	dsn  string
+	db   *sql.DB // sqlmock 自己的 db ，只用于关闭
+	mock sqlmock.Sqlmock
+	fail int32
+}
+
+var errSQLFailure = errors.New("harness: mysql failure")
+
+// mockSQLCount sqlmock 按 dsn 区分连接，每个拓扑使用不同的 dsn
+var mockSQLCount int32
+
+func newMockSQL() (*mockSQL, error) {
+	m := &mockSQL{dsn: fmt.Sprintf("root@tcp(harness%d)/mysql", atomic.AddInt32(&mockSQLCount, 1))}
+	var err error
+	m.db, m.mock, err = sqlmock.NewWithDSN(m.dsn,
+		sqlmock.MonitorPingsOption(true),
+		sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual),
+	)
+	if err != nil {
+		return nil, err
+	}
+	m.mock.MatchExpectationsInOrder(false)
+	return m, nil
+}
+
+func (m *mockSQL) setFail(fail bool) {
+	var v int32
+	if fail {
+		v = 1
+	}
+	atomic.StoreInt32(&m.fail, v)
+}
+
+// expect 登记一次 TestMySQL 的操作，调用次数由情景决定（例如调用死循环），所以每次调用前登记
+func (m *mockSQL) expect(name string) {
+	if atomic.LoadInt32(&m.fail) == 1 {
+		m.mock.ExpectPing().WillReturnError(errSQLFailure)
+		return
+	}
+	m.mock.ExpectPing()
+	m.mock.ExpectExec("UPDATE account SET visits = visits + 1 WHERE name = ?").
+		WithArgs(name).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
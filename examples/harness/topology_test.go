@@ -0,0 +1,289 @@
+package harness
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fananchong/tracer"
+	"github.com/fananchong/tracer/examples/proto"
+	"github.com/fananchong/tracer/spans"
+	"github.com/fananchong/tracer/tracetest"
+	"github.com/go-redis/redis"
+	"github.com/labstack/echo/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// topology 进程内的 server1 -> server2 -> server3 拓扑，与 examples/server1..3 相同
+//
+//	server1 ： Echo ， httptest 监听
+//	server2 、 server3 ： gRPC ， bufconn 连接
+//	Redis ： fakeRedis ， MySQL ： sqlmock
+//	tracer ： tracetest ，只记录到内存，不上报 jaeger agent
+type topology struct {
+	http    *httptest.Server
+	redis   *fakeRedis
+	sql     *mockSQL
+	db      *sql.DB
+	rdb     *redis.Client
+	servers []*grpc.Server
+	conns   []*grpc.ClientConn
+
+	server1Client proto.EchoClient // server1 -> server2
+	server2Client proto.EchoClient // server2 -> server3
+	server3Client proto.EchoClient // server3 -> server2 ，用于模拟调用死循环
+
+	loop int32 // server3 回调 server2 的剩余次数
+
+	rec  *tracetest.Recorder
+	once sync.Once
+}
+
+// newTopology 搭建拓扑，测试结束时关闭
+func newTopology(tb testing.TB) *topology {
+	tb.Helper()
+	t := &topology{redis: newFakeRedis()}
+	t.rec = tracetest.Start(tb, "server1", "server2", "server3")
+	if err := t.start(); err != nil {
+		tb.Fatalf("cannot start topology: %s", err.Error())
+	}
+	tb.Cleanup(t.close)
+	return t
+}
+
+func (t *topology) start() (err error) {
+	if t.sql, err = newMockSQL(); err != nil {
+		return err
+	}
+	if t.db, err = tracer.OpenDB(t.sql.db.Driver(), t.sql.dsn, "server3"); err != nil {
+		return err
+	}
+	t.rdb = redis.NewClient(&redis.Options{Dialer: t.redis.dial, Password: "123456"})
+
+	lis2, lis3 := bufconn.Listen(1<<20), bufconn.Listen(1<<20)
+	t.serve(lis2, "server2", &server2{t: t})
+	t.serve(lis3, "server3", &server3{t: t})
+	if t.server1Client, err = t.dial(lis2, "server1"); err != nil {
+		return err
+	}
+	if t.server2Client, err = t.dial(lis3, "server2"); err != nil {
+		return err
+	}
+	if t.server3Client, err = t.dial(lis2, "server3"); err != nil {
+		return err
+	}
+
+	e := echo.New()
+	e.HideBanner = true
+	e.Use(tracer.EchoMiddleware("server1"))
+	e.GET("/test1", t.test1)
+	e.GET("/test2", t.test2)
+	e.GET("/error1", error1)
+	e.GET("/error2", t.error2)
+	t.http = httptest.NewServer(e)
+	return nil
+}
+
+func (t *topology) serve(lis *bufconn.Listener, tracerName string, srv proto.EchoServer) {
+	s := grpc.NewServer(
+		tracer.RPCUnaryServerInterceptorOption(tracerName),
+		tracer.RPCStreamServerInterceptorOption(tracerName),
+	)
+	proto.RegisterEchoServer(s, srv)
+	go s.Serve(lis)
+	t.servers = append(t.servers, s)
+}
+
+func (t *topology) dial(lis *bufconn.Listener, tracerName string) (proto.EchoClient, error) {
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithInsecure(),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return lis.Dial()
+		}),
+		tracer.RPCUnaryClientInterceptorOption(tracerName),
+		tracer.RPCStreamClientInterceptorOption(tracerName),
+	)
+	if err != nil {
+		return nil, err
+	}
+	t.conns = append(t.conns, conn)
+	return proto.NewEchoClient(conn), nil
+}
+
+// close 关闭拓扑，等待所有请求处理完成：
+// httptest.Server.Close 等待 server1 的请求结束， GracefulStop 等待 server2 、 server3 的 RPC 结束，
+// 之后所有服务器端 span 都已经结束
+func (t *topology) close() {
+	t.once.Do(func() {
+		if t.http != nil {
+			t.http.Close()
+		}
+		for _, s := range t.servers {
+			s.GracefulStop()
+		}
+		for _, conn := range t.conns {
+			conn.Close()
+		}
+		if t.rdb != nil {
+			t.rdb.Close()
+		}
+		if t.db != nil {
+			t.db.Close()
+		}
+		if t.sql != nil {
+			t.sql.db.Close()
+		}
+	})
+}
+
+// allowFaults 打开、关闭各服务的故障注入
+func (t *topology) allowFaults(allow bool) {
+	for _, name := range []string{"server1", "server2", "server3"} {
+		o := tracer.GetOptions(name)
+		o.AllowFaults = allow
+		tracer.SetOptions(name, o)
+	}
+}
+
+// traces 关闭拓扑，返回记录的 trace
+func (t *topology) traces() []*spans.Trace {
+	t.close()
+	return t.rec.Traces()
+}
+
+// get 请求 server1
+func (t *topology) get(path string, header map[string]string) (int, string, error) {
+	req, err := http.NewRequest(http.MethodGet, t.http.URL+path, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, string(body), err
+}
+
+// server1
+
+func (t *topology) test1(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 30*time.Second)
+	defer cancel()
+	res, err := t.server1Client.UnaryEcho(ctx, &proto.EchoRequest{Message: "hello, Unary"})
+	if err != nil {
+		return c.String(http.StatusOK, fmt.Sprintf("error calling UnaryEcho: %v", err))
+	}
+	return c.String(http.StatusOK, res.GetMessage())
+}
+
+func (t *topology) test2(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), 10*time.Second)
+	defer cancel()
+	stream, err := t.server1Client.ServerStreamingEcho(ctx, &proto.EchoRequest{Message: "hello, ServerStreaming"})
+	if err != nil {
+		return c.String(http.StatusOK, fmt.Sprintf("error calling ServerStreamingEcho: %v", err))
+	}
+	n := 0
+	for {
+		if _, err = stream.Recv(); err != nil {
+			break
+		}
+		n++
+	}
+	return c.String(http.StatusOK, fmt.Sprintf("%d responses", n))
+}
+
+func error1(c echo.Context) (err error) {
+	defer func() {
+		if x := recover(); x != nil {
+			err = fmt.Errorf("%v\n%s", x, string(debug.Stack()))
+		}
+	}()
+	panic("test panic!!!!!!! test test test")
+}
+
+func (t *topology) error2(c echo.Context) error {
+	_, err := t.server1Client.UnaryEcho(c.Request().Context(), &proto.EchoRequest{Message: "panic"})
+	if err != nil {
+		return c.String(http.StatusOK, fmt.Sprintf("error calling UnaryEcho: %v", err))
+	}
+	return c.String(http.StatusOK, "no error")
+}
+
+// server2
+
+type server2 struct {
+	proto.UnimplementedEchoServer
+	t *topology
+}
+
+func (s *server2) UnaryEcho(ctx context.Context, in *proto.EchoRequest) (res *proto.EchoResponse, err error) {
+	if in.Message == "panic" {
+		defer func() {
+			if x := recover(); x != nil {
+				err = fmt.Errorf("panic: %v", x)
+			}
+		}()
+		panic("test gRPC panic")
+	}
+	s.t.server2Client.TestRedis(ctx, in)
+	s.t.server2Client.TestMySQL(ctx, in)
+	return &proto.EchoResponse{Message: fmt.Sprintf("%s %d", in.Message, rand.Int())}, nil
+}
+
+func (s *server2) ServerStreamingEcho(in *proto.EchoRequest, stream proto.Echo_ServerStreamingEchoServer) error {
+	for i := 0; i < 5; i++ {
+		if err := stream.Send(&proto.EchoResponse{Message: in.Message}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// server3
+
+type server3 struct {
+	proto.UnimplementedEchoServer
+	t *topology
+}
+
+func (s *server3) TestRedis(ctx context.Context, in *proto.EchoRequest) (*proto.EchoResponse, error) {
+	rclient := tracer.NewRedisClient(ctx, "server3", s.t.rdb)
+	rclient.Set("data", "TestRedis", 60*time.Second)
+	data, err := rclient.Get("data").Result()
+	if err != nil {
+		return nil, err
+	}
+	// 模拟调用死循环： server3 -> server2 -> server3 ...
+	if atomic.AddInt32(&s.t.loop, -1) >= 0 {
+		s.t.server3Client.UnaryEcho(ctx, &proto.EchoRequest{Message: "hello, Loop"})
+	}
+	return &proto.EchoResponse{Message: data}, nil
+}
+
+func (s *server3) TestMySQL(ctx context.Context, in *proto.EchoRequest) (*proto.EchoResponse, error) {
+	s.t.sql.expect(in.Message)
+	if err := s.t.db.PingContext(ctx); err != nil {
+		return nil, err
+	}
+	if _, err := s.t.db.ExecContext(ctx, "UPDATE account SET visits = visits + 1 WHERE name = ?", in.Message); err != nil {
+		return nil, err
+	}
+	return &proto.EchoResponse{Message: fmt.Sprintf("MySQL %d", rand.Int())}, nil
+}
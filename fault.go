@@ -0,0 +1,164 @@
+package tracer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+// 基于 baggage 的故障注入，用于故障演练
+// 只影响 baggage 中带 x-fault 的 trace ，且只在 Options.AllowFaults 打开的 tracer 上生效
+//
+// x-fault 由 ; 分隔的多条规则组成，每条规则为 目标[:选择器]:动作
+//   目标： redis 、 mysql （ MySQLPingWrap ）、 sql （ database/sql ）、 grpc （ gRPC 客户端）、 http （ EchoMiddleware 、 HTTPMiddleware ）
+//   选择器：可选，分别为 Redis 命令、 SQL 操作（ PING/QUERY/EXEC 等）、 gRPC 方法全名、 HTTP 路径，忽略大小写
+//   动作： error 、 error=消息、 delay=时长
+//
+// 例如：
+//   x-fault=redis:error;grpc:/proto.Echo/TestMySQL:delay=500ms
+//
+// 注入故障的 span 带 tag fault.injected=true ，并记录命中的规则
+
+// FaultBaggageKey 故障注入规则的 baggage key
+const FaultBaggageKey = "x-fault"
+
+// ErrFaultInjected 注入的错误
+var ErrFaultInjected = errors.New("fault injected")
+
+// 故障注入的目标
+const (
+	faultRedis = "redis"
+	faultMySQL = "mysql"
+	faultSQL   = "sql"
+	faultGRPC  = "grpc"
+	faultHTTP  = "http"
+)
+
+type faultRule struct {
+	raw      string
+	target   string
+	selector string
+	err      error
+	delay    time.Duration
+}
+
+// parseFaults 解析规则，忽略格式错误的规则
+func parseFaults(v string) []*faultRule {
+	var rules []*faultRule
+	for _, raw := range strings.Split(v, ";") {
+		raw = strings.TrimSpace(raw)
+		parts := strings.Split(raw, ":")
+		if len(parts) < 2 {
+			continue
+		}
+		r := &faultRule{
+			raw:      raw,
+			target:   strings.ToLower(parts[0]),
+			selector: strings.Join(parts[1:len(parts)-1], ":"),
+		}
+		action := strings.SplitN(parts[len(parts)-1], "=", 2)
+		switch action[0] {
+		case "error":
+			r.err = ErrFaultInjected
+			if len(action) == 2 && action[1] != "" {
+				r.err = &faultError{action[1]}
+			}
+		case "delay":
+			if len(action) != 2 {
+				continue
+			}
+			d, err := time.ParseDuration(action[1])
+			if err != nil || d <= 0 {
+				continue
+			}
+			r.delay = d
+		default:
+			continue
+		}
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// faultError 带消息的注入错误， errors.Is(err, ErrFaultInjected) 为 true
+type faultError struct {
+	message string
+}
+
+func (e *faultError) Error() string {
+	return ErrFaultInjected.Error() + ": " + e.message
+}
+
+func (e *faultError) Is(target error) bool {
+	return target == ErrFaultInjected
+}
+
+// faultsFor 获取 span context 的 baggage 中，与目标、选择器匹配的规则
+func faultsFor(tracerName string, sc opentracing.SpanContext, target, selector string) []*faultRule {
+	if sc == nil || !getOptions(tracerName).AllowFaults {
+		return nil
+	}
+	var v string
+	sc.ForeachBaggageItem(func(k, val string) bool {
+		if k == FaultBaggageKey {
+			v = val
+			return false
+		}
+		return true
+	})
+	if v == "" {
+		return nil
+	}
+	var rules []*faultRule
+	for _, r := range parseFaults(v) {
+		if r.target == target && (r.selector == "" || strings.EqualFold(r.selector, selector)) {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// applyFaults 执行规则：先等待所有延迟，再返回第一个错误
+func applyFaults(ctx context.Context, rules []*faultRule) error {
+	var err error
+	for _, r := range rules {
+		if r.delay > 0 {
+			t := time.NewTimer(r.delay)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+				return ctx.Err()
+			}
+		}
+		if r.err != nil && err == nil {
+			err = r.err
+		}
+	}
+	return err
+}
+
+// tagFaults 在 span 上标记注入的故障
+func tagFaults(span opentracing.Span, rules []*faultRule) {
+	if len(rules) == 0 {
+		return
+	}
+	span.SetTag("fault.injected", true)
+	for _, r := range rules {
+		span.LogFields(log.String("event", "fault injected"), log.String("fault.rule", r.raw))
+	}
+}
+
+// injectFault 按 span baggage 中的规则注入故障，返回注入的错误
+func injectFault(ctx context.Context, tracerName string, span opentracing.Span, target, selector string) error {
+	rules := faultsFor(tracerName, span.Context(), target, selector)
+	if len(rules) == 0 {
+		return nil
+	}
+	tagFaults(span, rules)
+	return applyFaults(ctx, rules)
+}
@@ -0,0 +1,75 @@
+package tracer_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/fananchong/tracer"
+	"github.com/fananchong/tracer/tracetest"
+	"github.com/go-redis/redis"
+	"github.com/opentracing/opentracing-go"
+)
+
+// TestRedisFault 注入错误时不发送命令，调用方得到规则中的错误消息
+func TestRedisFault(t *testing.T) {
+	rec := tracetest.Start(t, "redis")
+	tracer.SetOptions("redis", tracer.Options{AllowFaults: true})
+
+	var dials int32
+	rdb := redis.NewClient(&redis.Options{
+		Dialer: func() (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			return nil, errors.New("no redis")
+		},
+		MaxRetries: 0,
+	})
+	defer rdb.Close()
+
+	root := tracer.Get("redis").StartSpan("root")
+	root.SetBaggageItem(tracer.FaultBaggageKey, "redis:GET:error=drill")
+	ctx := opentracing.ContextWithSpan(context.Background(), root)
+	_, err := tracer.NewRedisClient(ctx, "redis", rdb).Get("key").Result()
+	root.Finish()
+
+	if !errors.Is(err, tracer.ErrFaultInjected) || !strings.Contains(err.Error(), "drill") {
+		t.Fatalf("got %v, want injected error with message", err)
+	}
+	if n := atomic.LoadInt32(&dials); n != 0 {
+		t.Errorf("command sent to redis: %d dials", n)
+	}
+	for _, s := range rec.Spans() {
+		if s.Operation == "GET" {
+			if !s.Error() || s.Tag("fault.injected") != "true" {
+				t.Errorf("got tags %v", s.Tags)
+			}
+			return
+		}
+	}
+	t.Fatal("no GET span")
+}
+
+// TestHTTPFaultDeadline 注入的延迟不超过请求头 X-Deadline-Ms 设置的 deadline
+func TestHTTPFaultDeadline(t *testing.T) {
+	tracetest.Start(t, "http")
+	tracer.SetOptions("http", tracer.Options{AllowFaults: true, Deadline: tracer.DeadlineOptions{AcceptHeader: true}})
+	h := tracer.HTTPMiddleware("http", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest("GET", "/slow", nil)
+	req.Header.Set(tracer.DeadlineHeader, "50")
+	req.Header.Set("uberctx-x-fault", "http:delay=5s")
+	w := httptest.NewRecorder()
+	start := time.Now()
+	h.ServeHTTP(w, req)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("fault delay ignored the deadline: %s", elapsed)
+	}
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("got status %d", w.Code)
+	}
+}
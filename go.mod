@@ -3,10 +3,12 @@ module github.com/fananchong/tracer
 go 1.14
 
 require (
+	github.com/DATA-DOG/go-sqlmock v1.5.2
 	github.com/codahale/hdrhistogram v0.0.0-20161010025455-3a0bb77429bd // indirect
-	github.com/go-redis/redis v6.15.9+incompatible
+	github.com/go-redis/redis v6.15.9+incompatible // setRedisCmdErr 依赖 baseCmd.err ，升级前运行 TestSetRedisCmdErr
 	github.com/go-sql-driver/mysql v1.5.0
 	github.com/golang/protobuf v1.4.2
+	github.com/google/pprof v0.0.0-20210407192527-94a9f03dee38
 	github.com/labstack/echo/v4 v4.1.16
 	github.com/onsi/ginkgo v1.14.0 // indirect
 	github.com/opentracing/opentracing-go v1.2.0
@@ -0,0 +1,19 @@
+package tracer_test
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/opentracing/opentracing-go"
+)
+
+func opentracingContext(span opentracing.Span) context.Context {
+	return opentracing.ContextWithSpan(context.Background(), span)
+}
+
+// roundTripFunc 函数形式的 http.RoundTripper
+type roundTripFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
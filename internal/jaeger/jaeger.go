@@ -3,19 +3,55 @@ package jaeger
 import (
 	"fmt"
 	"io"
+	"sort"
 	"sync"
 
+	"github.com/fananchong/tracer/spans"
 	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
 	"github.com/uber/jaeger-client-go/config"
 )
 
 // Jaeger 管理 jaeger tracer 实例
 type Jaeger struct {
-	tracers sync.Map
+	tracers  sync.Map
+	samplers sync.Map // tracer 名字 -> *dynamicSampler
+	tail     *TailSamplingConfig
+	sinks    []spans.Sink
+	noAgent  bool
 }
 
-func New() *Jaeger {
-	return &Jaeger{}
+// Option Jaeger 选项
+type Option func(*Jaeger)
+
+// WithTailSampling 打开尾部采样
+func WithTailSampling(cfg TailSamplingConfig) Option {
+	return func(j *Jaeger) {
+		j.tail = &cfg
+	}
+}
+
+// WithSink 已结束的 span 同时写入 sink
+func WithSink(sink spans.Sink) Option {
+	return func(j *Jaeger) {
+		j.sinks = append(j.sinks, sink)
+	}
+}
+
+// WithoutAgent 不上报 jaeger agent ， span 只写入 sink
+func WithoutAgent() Option {
+	return func(j *Jaeger) {
+		j.noAgent = true
+	}
+}
+
+// New Jaeger 构造函数
+func New(opts ...Option) *Jaeger {
+	j := &Jaeger{}
+	for _, opt := range opts {
+		opt(j)
+	}
+	return j
 }
 
 // Enable 打开 tracer
@@ -36,7 +72,12 @@ func (j *Jaeger) Enable(name string) (err error) {
 		fmt.Printf("cannot parse jaeger env vars: %s\n", err.Error())
 		return
 	}
-	tracer, closer, err := cfg.NewTracer()
+	reporter, err := j.newReporter(name, cfg)
+	if err != nil {
+		fmt.Printf("cannot initialize jaeger reporter: %s\n", err.Error())
+		return
+	}
+	tracer, closer, err := cfg.NewTracer(config.Reporter(reporter), config.Sampler(j.sampler(name)))
 	if err != nil {
 		fmt.Printf("cannot initialize jaeger tracer: %s\n", err.Error())
 		return
@@ -45,6 +86,45 @@ func (j *Jaeger) Enable(name string) (err error) {
 	return
 }
 
+// newReporter 创建 reporter ，同时写入 sink ；打开尾部采样时，包装一层尾部采样
+func (j *Jaeger) newReporter(name string, cfg *config.Configuration) (jaeger.Reporter, error) {
+	var reporters []jaeger.Reporter
+	if !j.noAgent {
+		reporter, err := cfg.Reporter.NewReporter(name, jaeger.NewNullMetrics(), jaeger.NullLogger)
+		if err != nil {
+			return nil, err
+		}
+		reporters = append(reporters, reporter)
+	}
+	for _, sink := range j.sinks {
+		reporters = append(reporters, &sinkReporter{service: name, sink: sink})
+	}
+	var reporter jaeger.Reporter
+	switch len(reporters) {
+	case 0:
+		reporter = jaeger.NewNullReporter()
+	case 1:
+		reporter = reporters[0]
+	default:
+		reporter = jaeger.NewCompositeReporter(reporters...)
+	}
+	if j.tail != nil {
+		reporter = newTailReporter(name, reporter, *j.tail)
+	}
+	return reporter, nil
+}
+
+// sampler 获取 tracer 的采样器，默认全部采样
+func (j *Jaeger) sampler(name string) *dynamicSampler {
+	x, _ := j.samplers.LoadOrStore(name, newDynamicSampler())
+	return x.(*dynamicSampler)
+}
+
+// SetSamplingRate 设置 tracer 的采样率（ 0 ~ 1 ），立即生效。 tracer 还没打开时，打开后生效
+func (j *Jaeger) SetSamplingRate(name string, rate float64) error {
+	return j.sampler(name).setRate(rate)
+}
+
 // Disable 关闭 tracer
 func (j *Jaeger) Disable(name string) {
 	if x, ok := j.tracers.Load(name); ok {
@@ -53,6 +133,31 @@ func (j *Jaeger) Disable(name string) {
 	}
 }
 
+// Close 关闭所有创建过的 tracer ，上报（或写入 sink ）缓存中的 span
+// 之后不能再使用
+func (j *Jaeger) Close() error {
+	var firstErr error
+	j.tracers.Range(func(k, x interface{}) bool {
+		j.tracers.Delete(k)
+		if err := x.(*tracerWrap).closer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		return true
+	})
+	return firstErr
+}
+
+// Names 获取所有创建过的 tracer 名字，包括已经关闭的
+func (j *Jaeger) Names() []string {
+	var names []string
+	j.tracers.Range(func(k, _ interface{}) bool {
+		names = append(names, k.(string))
+		return true
+	})
+	sort.Strings(names)
+	return names
+}
+
 // Get 获取 tracer
 func (j *Jaeger) Get(name string) opentracing.Tracer {
 	if x, ok := j.tracers.Load(name); ok {
@@ -63,6 +168,69 @@ func (j *Jaeger) Get(name string) opentracing.Tracer {
 	return nil
 }
 
+// TraceID 获取 span context 的 trace id （ 32 位十六进制，与上报的 span 相同）
+func (j *Jaeger) TraceID(sc opentracing.SpanContext) string {
+	if c, ok := sc.(jaeger.SpanContext); ok && c.IsValid() {
+		return traceIDString(c.TraceID())
+	}
+	return ""
+}
+
+// SpanContextIDs 获取 span context 的 trace id （ 32 位十六进制）、 span id （ 16 位十六进制）、是否采样
+func (j *Jaeger) SpanContextIDs(sc opentracing.SpanContext) (traceID, spanID string, sampled, ok bool) {
+	c, ok := sc.(jaeger.SpanContext)
+	if !ok || !c.IsValid() {
+		return "", "", false, false
+	}
+	return traceIDString(c.TraceID()), spanIDString(c.SpanID()), c.IsSampled(), true
+}
+
+// NewSpanContext 由 trace id 、 span id 构造 span context ，用于从其他格式（ W3C 、 B3 ）中提取
+// sampled 为 nil 时，由本地采样器决定是否采样；否则与 uber-trace-id 一样，子 span 沿用调用方的采样决定
+func (j *Jaeger) NewSpanContext(traceID, spanID string, sampled *bool, baggage map[string]string) (opentracing.SpanContext, error) {
+	t, err := jaeger.TraceIDFromString(traceID)
+	if err != nil {
+		return nil, err
+	}
+	s, err := jaeger.SpanIDFromString(spanID)
+	if err != nil {
+		return nil, err
+	}
+	if sampled == nil {
+		return jaeger.NewSpanContext(t, s, 0, false, baggage), nil
+	}
+	// 只有 Tracer.Extract 得到的 span context 标记为远程，子 span 才不再调用采样器
+	flags := 0
+	if *sampled {
+		flags = 1
+	}
+	carrier := opentracing.TextMapCarrier{
+		jaeger.TraceContextHeaderName: fmt.Sprintf("%s:%s:0:%d", t, s, flags),
+	}
+	x, err := remoteTracer().Extract(opentracing.TextMap, carrier)
+	if err != nil {
+		return nil, err
+	}
+	sc := x.(jaeger.SpanContext)
+	for k, v := range baggage {
+		sc = sc.WithBaggageItem(k, v)
+	}
+	return sc, nil
+}
+
+var (
+	remoteTracerOnce sync.Once
+	remoteTracerInst opentracing.Tracer
+)
+
+// remoteTracer 只用于 Extract 远程 span context ，不创建 span
+func remoteTracer() opentracing.Tracer {
+	remoteTracerOnce.Do(func() {
+		remoteTracerInst, _ = jaeger.NewTracer("remote", jaeger.NewConstSampler(false), jaeger.NewNullReporter())
+	})
+	return remoteTracerInst
+}
+
 type tracerWrap struct {
 	tracer opentracing.Tracer
 	closer io.Closer
@@ -0,0 +1,54 @@
+package jaeger
+
+import (
+	"sync/atomic"
+
+	"github.com/uber/jaeger-client-go"
+)
+
+// dynamicSampler 可以随时修改采样率的采样器
+type dynamicSampler struct {
+	sampler atomic.Value // jaeger.Sampler
+}
+
+func newDynamicSampler() *dynamicSampler {
+	s := &dynamicSampler{}
+	s.sampler.Store(jaeger.Sampler(jaeger.NewConstSampler(true)))
+	return s
+}
+
+// setRate 设置采样率， 0 ~ 1
+func (s *dynamicSampler) setRate(rate float64) error {
+	var sampler jaeger.Sampler
+	switch {
+	case rate >= 1:
+		sampler = jaeger.NewConstSampler(true)
+	case rate <= 0:
+		sampler = jaeger.NewConstSampler(false)
+	default:
+		p, err := jaeger.NewProbabilisticSampler(rate)
+		if err != nil {
+			return err
+		}
+		sampler = p
+	}
+	s.sampler.Store(sampler)
+	return nil
+}
+
+func (s *dynamicSampler) get() jaeger.Sampler {
+	return s.sampler.Load().(jaeger.Sampler)
+}
+
+// IsSampled 实现 jaeger.Sampler
+func (s *dynamicSampler) IsSampled(id jaeger.TraceID, operation string) (bool, []jaeger.Tag) {
+	return s.get().IsSampled(id, operation)
+}
+
+// Close 实现 jaeger.Sampler
+func (s *dynamicSampler) Close() {}
+
+// Equal 实现 jaeger.Sampler
+func (s *dynamicSampler) Equal(other jaeger.Sampler) bool {
+	return s == other
+}
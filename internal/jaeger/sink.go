@@ -0,0 +1,87 @@
+package jaeger
+
+import (
+	"fmt"
+
+	"github.com/fananchong/tracer/spans"
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+)
+
+// sinkReporter 把 span 转换为 spans.Span ，写入 sink
+type sinkReporter struct {
+	service string
+	sink    spans.Sink
+}
+
+func (r *sinkReporter) Report(span *jaeger.Span) {
+	r.sink.WriteSpan(toSpan(r.service, span))
+}
+
+func (r *sinkReporter) Close() {
+}
+
+func toSpan(service string, span *jaeger.Span) *spans.Span {
+	ctx := span.SpanContext()
+	s := &spans.Span{
+		TraceID:   traceIDString(ctx.TraceID()),
+		SpanID:    spanIDString(ctx.SpanID()),
+		Service:   service,
+		Operation: span.OperationName(),
+		Start:     span.StartTime(),
+		Duration:  span.Duration(),
+	}
+	if ctx.ParentID() != 0 {
+		s.ParentID = spanIDString(ctx.ParentID())
+	}
+	for _, ref := range span.References() {
+		refCtx, ok := ref.ReferencedContext.(jaeger.SpanContext)
+		if !ok {
+			continue
+		}
+		typ := "child_of"
+		if ref.Type == opentracing.FollowsFromRef {
+			typ = "follows_from"
+		}
+		s.References = append(s.References, spans.Reference{
+			Type:    typ,
+			TraceID: traceIDString(refCtx.TraceID()),
+			SpanID:  spanIDString(refCtx.SpanID()),
+		})
+	}
+	if tags := span.Tags(); len(tags) > 0 {
+		s.Tags = make(map[string]interface{}, len(tags))
+		for k, v := range tags {
+			s.Tags[k] = jsonValue(v)
+		}
+	}
+	for _, l := range span.Logs() {
+		fields := make(map[string]interface{}, len(l.Fields))
+		for _, f := range l.Fields {
+			fields[f.Key()] = jsonValue(f.Value())
+		}
+		s.Logs = append(s.Logs, spans.Log{Timestamp: l.Timestamp, Fields: fields})
+	}
+	return s
+}
+
+// traceIDString 32 位十六进制的 trace id （ jaeger 的 String() 去掉了前导 0 ，长度不固定）
+func traceIDString(id jaeger.TraceID) string {
+	return fmt.Sprintf("%016x%016x", id.High, id.Low)
+}
+
+// spanIDString 16 位十六进制的 span id
+func spanIDString(id jaeger.SpanID) string {
+	return fmt.Sprintf("%016x", uint64(id))
+}
+
+// jsonValue 保留基本类型，其他类型转换为字符串
+func jsonValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case nil, string, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return x
+	case error:
+		return x.Error()
+	}
+	return fmt.Sprint(v)
+}
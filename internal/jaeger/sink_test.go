@@ -0,0 +1,31 @@
+package jaeger
+
+import (
+	"testing"
+
+	"github.com/fananchong/tracer/spans"
+	"github.com/opentracing/opentracing-go"
+	"github.com/uber/jaeger-client-go"
+)
+
+// TestToSpanIDs trace id 补齐为 32 位， span id 补齐为 16 位
+func TestToSpanIDs(t *testing.T) {
+	var got []*spans.Span
+	sink := &sinkReporter{service: "sink", sink: spans.SinkFunc(func(s *spans.Span) { got = append(got, s) })}
+	tracer, closer := jaeger.NewTracer("sink", jaeger.NewConstSampler(true), sink)
+	defer closer.Close()
+
+	parent := jaeger.NewSpanContext(jaeger.TraceID{Low: 0xabc}, jaeger.SpanID(0x12), 0, true, nil)
+	tracer.StartSpan("op", opentracing.ChildOf(parent)).Finish()
+
+	if len(got) != 1 {
+		t.Fatalf("got %d spans", len(got))
+	}
+	s := got[0]
+	if s.TraceID != "00000000000000000000000000000abc" || len(s.SpanID) != 16 || s.ParentID != "0000000000000012" {
+		t.Errorf("got trace %q span %q parent %q", s.TraceID, s.SpanID, s.ParentID)
+	}
+	if len(s.References) != 1 || s.References[0].TraceID != s.TraceID || s.References[0].SpanID != s.ParentID {
+		t.Errorf("got references %+v", s.References)
+	}
+}
@@ -0,0 +1,250 @@
+package jaeger
+
+import (
+	"container/list"
+	"expvar"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/uber/jaeger-client-go"
+)
+
+// TailSamplingConfig 尾部采样配置
+// span 结束后先按 trace 缓存 Window 时间，再决定整个 trace 是否上报：
+//   - 含有 error=true 的 span 、 sampling.priority > 0 的 span （比如慢查询强制采样），保留
+//   - 任一 span 耗时超过 LatencyThreshold ，保留
+//   - 任一 span 的 tag 与 Tags 匹配，保留
+//   - 其他 trace 按 Probability 概率保留。与 jaeger 的概率采样器一样按 trace id 计算，
+//     所有进程对同一个 trace 的决定相同
+//
+// 只在本进程内缓存、决定：一个 trace 跨多个进程时，各进程分别决定自己的 span ，
+// 因错误、耗时、 tag 保留的 trace 在其他进程中可能按概率被丢弃
+type TailSamplingConfig struct {
+	Window           time.Duration     // 缓存时间，默认 5s
+	LatencyThreshold time.Duration     // 耗时阈值， 0 表示不检查
+	Tags             map[string]string // 需要保留的 tag ，值按 fmt.Sprint 比较
+	Probability      float64           // 其他 trace 的保留概率， 0 ~ 1
+	MaxSpans         int               // 最多缓存的 span 个数，超出时提前决定最早的 trace ，默认 10000
+}
+
+// tailStats 尾部采样计数，导出到 expvar （ tracer.tail_sampling.<name> ）
+type tailStats struct {
+	kept    expvar.Int // 保留的 trace
+	dropped expvar.Int // 按概率丢弃的 trace
+	evicted expvar.Int // 缓存超出上限，被提前决定的 trace
+	late    expvar.Int // 决定之后才结束的 span
+	spans   expvar.Int // 当前缓存的 span
+}
+
+var tailStatsVars = expvar.NewMap("tracer.tail_sampling")
+
+type tailTrace struct {
+	spans []*jaeger.Span
+	first time.Time
+	keep  bool
+	elem  *list.Element
+}
+
+type tailDecision struct {
+	keep bool
+	at   time.Time
+}
+
+// tailReporter 尾部采样 reporter ，决定保留的 trace 转给 next 上报
+type tailReporter struct {
+	next  jaeger.Reporter
+	cfg   TailSamplingConfig
+	stats *tailStats
+
+	mu      sync.Mutex
+	traces  map[jaeger.TraceID]*tailTrace
+	order   *list.List // 按第一个 span 到达时间排序的 trace id
+	decided map[jaeger.TraceID]tailDecision
+	spans   int
+	// boundary trace id 低 64 位（去掉最高位）不超过该值的 trace 按概率保留
+	boundary uint64
+
+	done      chan struct{}
+	closed    sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// maxRandomNumber 与 jaeger 概率采样器相同，只使用 trace id 低 64 位中的 63 位
+const maxRandomNumber = ^(uint64(1) << 63)
+
+func newTailReporter(name string, next jaeger.Reporter, cfg TailSamplingConfig) *tailReporter {
+	if cfg.Window <= 0 {
+		cfg.Window = 5 * time.Second
+	}
+	if cfg.MaxSpans <= 0 {
+		cfg.MaxSpans = 10000
+	}
+	r := &tailReporter{
+		next:    next,
+		cfg:     cfg,
+		stats:   &tailStats{},
+		traces:  make(map[jaeger.TraceID]*tailTrace),
+		order:   list.New(),
+		decided: make(map[jaeger.TraceID]tailDecision),
+		done:    make(chan struct{}),
+	}
+	if cfg.Probability > 0 {
+		r.boundary = uint64(float64(maxRandomNumber) * cfg.Probability)
+	}
+	v := new(expvar.Map).Init()
+	v.Set("kept", &r.stats.kept)
+	v.Set("dropped", &r.stats.dropped)
+	v.Set("evicted", &r.stats.evicted)
+	v.Set("late", &r.stats.late)
+	v.Set("spans", &r.stats.spans)
+	tailStatsVars.Set(name, v)
+
+	r.closed.Add(1)
+	go r.loop()
+	return r
+}
+
+// Report 实现 jaeger.Reporter
+func (r *tailReporter) Report(span *jaeger.Span) {
+	id := span.SpanContext().TraceID()
+	var forward []*jaeger.Span
+	var drop []*jaeger.Span
+
+	r.mu.Lock()
+	if d, ok := r.decided[id]; ok {
+		r.mu.Unlock()
+		r.stats.late.Add(1)
+		if d.keep {
+			r.next.Report(span)
+		}
+		return
+	}
+	t, ok := r.traces[id]
+	if !ok {
+		t = &tailTrace{first: time.Now()}
+		t.elem = r.order.PushBack(id)
+		r.traces[id] = t
+	}
+	t.spans = append(t.spans, span.Retain())
+	t.keep = t.keep || r.interesting(span)
+	r.spans++
+	for r.spans > r.cfg.MaxSpans && r.order.Len() > 0 {
+		oldest := r.order.Front().Value.(jaeger.TraceID)
+		r.stats.evicted.Add(1)
+		f, d := r.decideLocked(oldest, time.Now())
+		forward, drop = append(forward, f...), append(drop, d...)
+	}
+	r.stats.spans.Set(int64(r.spans))
+	r.mu.Unlock()
+
+	r.flush(forward, drop)
+}
+
+// interesting span 是否满足保留条件
+func (r *tailReporter) interesting(span *jaeger.Span) bool {
+	if r.cfg.LatencyThreshold > 0 && span.Duration() >= r.cfg.LatencyThreshold {
+		return true
+	}
+	for k, v := range span.Tags() {
+		switch k {
+		case "error":
+			if b, ok := v.(bool); ok && b {
+				return true
+			}
+		case "sampling.priority":
+			if fmt.Sprint(v) != "0" {
+				return true
+			}
+		}
+		if want, ok := r.cfg.Tags[k]; ok && fmt.Sprint(v) == want {
+			return true
+		}
+	}
+	return false
+}
+
+// decideLocked 决定 trace 是否保留，返回需要上报、丢弃的 span
+func (r *tailReporter) decideLocked(id jaeger.TraceID, now time.Time) (forward, drop []*jaeger.Span) {
+	t := r.traces[id]
+	keep := t.keep
+	if keep {
+		r.stats.kept.Add(1)
+	} else if r.cfg.Probability > 0 && id.Low&maxRandomNumber <= r.boundary {
+		keep = true
+		r.stats.kept.Add(1)
+	} else {
+		r.stats.dropped.Add(1)
+	}
+	r.order.Remove(t.elem)
+	delete(r.traces, id)
+	r.spans -= len(t.spans)
+	r.decided[id] = tailDecision{keep: keep, at: now}
+	if keep {
+		return t.spans, nil
+	}
+	return nil, t.spans
+}
+
+func (r *tailReporter) flush(forward, drop []*jaeger.Span) {
+	for _, span := range forward {
+		r.next.Report(span)
+		span.Release()
+	}
+	for _, span := range drop {
+		span.Release()
+	}
+}
+
+func (r *tailReporter) loop() {
+	defer r.closed.Done()
+	interval := r.cfg.Window / 4
+	if interval < 100*time.Millisecond {
+		interval = 100 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			r.expire(now, false)
+		case <-r.done:
+			r.expire(time.Now(), true)
+			return
+		}
+	}
+}
+
+// expire 决定已超过缓存时间的 trace ， all 为 true 时决定所有 trace
+func (r *tailReporter) expire(now time.Time, all bool) {
+	var forward, drop []*jaeger.Span
+	r.mu.Lock()
+	for e := r.order.Front(); e != nil; {
+		id := e.Value.(jaeger.TraceID)
+		e = e.Next()
+		if !all && now.Sub(r.traces[id].first) < r.cfg.Window {
+			break
+		}
+		f, d := r.decideLocked(id, now)
+		forward, drop = append(forward, f...), append(drop, d...)
+	}
+	// 决定结果保留 2 个缓存时间，用于处理迟到的 span
+	for id, d := range r.decided {
+		if now.Sub(d.at) > 2*r.cfg.Window {
+			delete(r.decided, id)
+		}
+	}
+	r.stats.spans.Set(int64(r.spans))
+	r.mu.Unlock()
+
+	r.flush(forward, drop)
+}
+
+// Close 实现 jaeger.Reporter ，决定所有缓存的 trace 后关闭，可以多次调用
+func (r *tailReporter) Close() {
+	r.closeOnce.Do(func() {
+		close(r.done)
+		r.closed.Wait()
+		r.next.Close()
+	})
+}
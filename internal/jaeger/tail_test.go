@@ -0,0 +1,97 @@
+package jaeger
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/uber/jaeger-client-go"
+)
+
+// traceReporter 记录上报的 span 的 trace id （低 64 位）
+type traceReporter struct {
+	mu  sync.Mutex
+	ids []uint64
+}
+
+func (r *traceReporter) Report(span *jaeger.Span) {
+	r.mu.Lock()
+	r.ids = append(r.ids, span.SpanContext().TraceID().Low)
+	r.mu.Unlock()
+}
+
+func (r *traceReporter) Close() {}
+
+func (r *traceReporter) traceIDs() []uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]uint64(nil), r.ids...)
+}
+
+// newTailTracer 使用尾部采样 reporter 的 tracer ，保留的 span 写入返回的 traceReporter
+func newTailTracer(t *testing.T, cfg TailSamplingConfig) (opentracing.Tracer, *tailReporter, *traceReporter) {
+	t.Helper()
+	mem := &traceReporter{}
+	tail := newTailReporter(t.Name(), mem, cfg)
+	tracer, closer := jaeger.NewTracer("tail", jaeger.NewConstSampler(true), tail)
+	t.Cleanup(func() { closer.Close() })
+	return tracer, tail, mem
+}
+
+// finishTrace 创建一个指定 trace id 的 span 并结束
+func finishTrace(tracer opentracing.Tracer, low uint64, isError bool) {
+	parent := jaeger.NewSpanContext(jaeger.TraceID{Low: low}, jaeger.SpanID(low), 0, true, nil)
+	span := tracer.StartSpan("op", opentracing.ChildOf(parent))
+	if isError {
+		ext.Error.Set(span, true)
+	}
+	span.Finish()
+}
+
+// TestTailProbabilityByTraceID 按 trace id 决定，不同 reporter 对同一个 trace 的决定相同
+func TestTailProbabilityByTraceID(t *testing.T) {
+	cfg := TailSamplingConfig{Window: time.Hour, Probability: 0.5}
+	kept := func() map[uint64]bool {
+		tracer, tail, mem := newTailTracer(t, cfg)
+		for i := uint64(1); i <= 200; i++ {
+			finishTrace(tracer, i*0x9e3779b97f4a7c15, false)
+		}
+		tail.Close()
+		m := map[uint64]bool{}
+		for _, id := range mem.traceIDs() {
+			m[id] = true
+		}
+		return m
+	}
+	a, b := kept(), kept()
+	if len(a) == 0 || len(a) == 200 {
+		t.Fatalf("kept %d of 200 traces", len(a))
+	}
+	if len(a) != len(b) {
+		t.Fatalf("kept %d and %d traces", len(a), len(b))
+	}
+	for id := range a {
+		if !b[id] {
+			t.Fatalf("trace %x kept by one reporter only", id)
+		}
+	}
+}
+
+func TestTailKeepError(t *testing.T) {
+	tracer, tail, mem := newTailTracer(t, TailSamplingConfig{Window: time.Hour})
+	finishTrace(tracer, 1, false)
+	finishTrace(tracer, 2, true)
+	tail.Close()
+	got := mem.traceIDs()
+	if len(got) != 1 || got[0] != 2 {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func TestTailCloseTwice(t *testing.T) {
+	_, tail, _ := newTailTracer(t, TailSamplingConfig{})
+	tail.Close()
+	tail.Close()
+}
@@ -0,0 +1,103 @@
+package tracer
+
+import (
+	"strings"
+	"sync"
+)
+
+// Options tracer 对应的各集成选项
+// 按 tracer 名字保存，各集成每次调用时读取，因此可以随时修改
+type Options struct {
+	// Propagation 跨进程传递 span context 的格式，为空时使用 tracer 自身的格式
+	// 配置多个格式时，注入所有格式，按顺序提取第一个成功的格式（方便迁移）
+	Propagation []PropagationFormat
+
+	// AllowFaults 是否允许 baggage 中的 x-fault 注入故障，生产环境不要打开
+	AllowFaults bool
+
+	// Skip 不追踪的操作（ HTTP 路径、 gRPC 方法名，例如健康检查），以 * 结尾表示前缀匹配
+	Skip []string
+
+	HTTP     HTTPOptions
+	GRPC     GRPCOptions
+	SQL      SQLOptions
+	Redis    RedisOptions
+	Deadline DeadlineOptions
+	Cancel   CancelOptions
+	Profile  ProfileOptions
+	Watchdog WatchdogOptions
+}
+
+// HTTPOptions HTTP 集成选项（ EchoMiddleware 、 HTTPMiddleware ）
+type HTTPOptions struct {
+	// TraceIDHeader 不为空时，在应答头中返回 trace id ，例如 X-Trace-Id
+	TraceIDHeader string
+}
+
+// GRPCOptions gRPC 集成选项
+type GRPCOptions struct {
+	// TraceIDHeader 不为空时，服务器端在 header 、 trailer 中返回 trace id ，例如 x-trace-id
+	TraceIDHeader string
+	// NoPayload 不在 span 上记录请求、应答内容（消息较大或含敏感数据时）
+	NoPayload bool
+}
+
+// SQLOptions SQL 集成选项（ database/sql 驱动封装、 MySQLPingWrap ）
+type SQLOptions struct {
+	// Statement db.statement 记录方式
+	Statement StatementMode
+	// ANSIQuotes 规范化时双引号为标识符（ MySQL ANSI_QUOTES 、 PostgreSQL 等），默认双引号为字符串
+	ANSIQuotes bool
+	// LogParams 是否单独记录绑定参数（ db.params ）
+	LogParams bool
+	// Redact 绑定参数、完整语句的脱敏规则
+	Redact []RedactRule
+	// Slow 慢查询阈值
+	Slow SlowOptions
+}
+
+// RedisOptions Redis 集成选项
+type RedisOptions struct {
+	// Statement db.statement 记录方式
+	Statement StatementMode
+	// Redact 完整命令的脱敏规则
+	Redact []RedactRule
+	// Slow 慢命令阈值
+	Slow SlowOptions
+}
+
+var options sync.Map
+
+var defaultOptions = &Options{}
+
+// SetOptions 设置 tracer 的集成选项
+func SetOptions(name string, o Options) {
+	options.Store(name, &o)
+}
+
+// GetOptions 获取 tracer 的集成选项
+func GetOptions(name string) Options {
+	return *getOptions(name)
+}
+
+// getOptions 获取 tracer 的集成选项，返回值只读
+func getOptions(name string) *Options {
+	if x, ok := options.Load(name); ok {
+		return x.(*Options)
+	}
+	return defaultOptions
+}
+
+// skipped 操作是否在 tracer 的 Skip 列表中
+func skipped(tracerName, operation string) bool {
+	for _, pattern := range getOptions(tracerName).Skip {
+		if strings.HasSuffix(pattern, "*") {
+			if strings.HasPrefix(operation, pattern[:len(pattern)-1]) {
+				return true
+			}
+		} else if operation == pattern {
+			return true
+		}
+	}
+	return false
+}
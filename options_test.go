@@ -0,0 +1,33 @@
+package tracer
+
+import (
+	"testing"
+)
+
+func TestSkipped(t *testing.T) {
+	const name = "skipped_test"
+	SetOptions(name, Options{Skip: []string{"/health", "/grpc.health.v1.Health/*", "/static/*"}})
+	defer SetOptions(name, Options{})
+	tests := []struct {
+		operation string
+		want      bool
+	}{
+		{"/health", true},
+		{"/health/live", false},
+		{"/healthz", false},
+		{"/grpc.health.v1.Health/Check", true},
+		{"/grpc.health.v1.Health/", true},
+		{"/grpc.health.v1.Healthz", false},
+		{"/static/app.js", true},
+		{"/api/static/app.js", false},
+		{"/test1", false},
+	}
+	for _, tt := range tests {
+		if got := skipped(name, tt.operation); got != tt.want {
+			t.Errorf("skipped(%q) = %v, want %v", tt.operation, got, tt.want)
+		}
+	}
+	if skipped("skipped_test_other", "/health") {
+		t.Error("Skip applied to another tracer")
+	}
+}
@@ -0,0 +1,46 @@
+package tracer
+
+import (
+	"context"
+	"runtime/pprof"
+
+	"github.com/opentracing/opentracing-go"
+)
+
+// pprof 标签名，可以用 go tool pprof -tagfocus 、 tracecat profile 按标签过滤
+const (
+	ProfileLabelTraceID   = "trace_id"
+	ProfileLabelSpanID    = "span_id"
+	ProfileLabelService   = "service"
+	ProfileLabelOperation = "operation"
+)
+
+// ProfileOptions profile 关联选项
+type ProfileOptions struct {
+	// Labels 服务器端 handler 是否在 pprof.Do 中执行，带上 trace id 、 span id 、服务名、操作名标签
+	// CPU profile 中的样本因此可以按 trace 、操作过滤。 handler 中启动的 goroutine 继承这些标签
+	Labels bool
+}
+
+// doWithProfileLabels 打开了 Profile.Labels 时，在 pprof.Do 中执行 f ，否则直接执行 f
+func doWithProfileLabels(ctx context.Context, tracerName string, span opentracing.Span, operation string, f func(ctx context.Context)) {
+	if !getOptions(tracerName).Profile.Labels {
+		f(ctx)
+		return
+	}
+	pprof.Do(ctx, pprof.Labels(profileLabels(tracerName, span, operation)...), f)
+}
+
+func profileLabels(tracerName string, span opentracing.Span, operation string) []string {
+	labels := []string{ProfileLabelService, tracerName, ProfileLabelOperation, operation}
+	sc := span.Context()
+	if id := traceIDOf(span.Tracer(), sc); id != "" {
+		labels = append(labels, ProfileLabelTraceID, id)
+	}
+	if codec, err := codecOf(); err == nil {
+		if _, spanID, _, ok := codec.SpanContextIDs(sc); ok {
+			labels = append(labels, ProfileLabelSpanID, spanID)
+		}
+	}
+	return labels
+}
@@ -0,0 +1,126 @@
+package tracer
+
+import (
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+)
+
+// PropagationFormat 跨进程传递 span context 的格式
+type PropagationFormat int
+
+const (
+	// PropagationNative tracer 自身的格式（ jaeger 为 uber-trace-id ）
+	PropagationNative PropagationFormat = iota
+	// PropagationW3C W3C Trace Context （ traceparent/tracestate ），以及 W3C baggage
+	PropagationW3C
+	// PropagationB3 Zipkin B3 多 header 格式（ X-B3-TraceId 、 X-B3-SpanId 等）
+	PropagationB3
+	// PropagationB3Single Zipkin B3 单 header 格式（ b3 ）
+	PropagationB3Single
+)
+
+// spanContextCodec ITracer 可选实现的接口，用于 span context 与 W3C 、 B3 等格式互转
+// traceID 为 32 位十六进制， spanID 为 16 位十六进制
+// NewSpanContext 的 sampled 为 nil 表示调用方没有做采样决定，由本地采样器决定
+type spanContextCodec interface {
+	SpanContextIDs(sc opentracing.SpanContext) (traceID, spanID string, sampled, ok bool)
+	NewSpanContext(traceID, spanID string, sampled *bool, baggage map[string]string) (opentracing.SpanContext, error)
+}
+
+// propagator 一种传递格式的实现
+// format 、 carrier 同 opentracing.Tracer 的 Inject/Extract 参数
+type propagator interface {
+	inject(tracer opentracing.Tracer, sc opentracing.SpanContext, format, carrier interface{}) error
+	extract(tracer opentracing.Tracer, format, carrier interface{}) (opentracing.SpanContext, error)
+}
+
+var propagators = map[PropagationFormat]propagator{
+	PropagationNative:   nativePropagator{},
+	PropagationW3C:      w3cPropagator{},
+	PropagationB3:       b3Propagator{},
+	PropagationB3Single: b3SinglePropagator{},
+}
+
+var defaultPropagation = []PropagationFormat{PropagationNative}
+
+func propagationFormats(tracerName string) []PropagationFormat {
+	if formats := getOptions(tracerName).Propagation; len(formats) > 0 {
+		return formats
+	}
+	return defaultPropagation
+}
+
+// injectTo 按 tracer 配置的所有格式注入 span context
+func injectTo(tracerName string, tracer opentracing.Tracer, sc opentracing.SpanContext, format, carrier interface{}) error {
+	var firstErr error
+	for _, f := range propagationFormats(tracerName) {
+		if p, ok := propagators[f]; ok {
+			if err := p.inject(tracer, sc, format, carrier); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// extractFrom 按 tracer 配置的格式顺序提取 span context ，返回第一个成功的结果
+func extractFrom(tracerName string, tracer opentracing.Tracer, format, carrier interface{}) (opentracing.SpanContext, error) {
+	err := opentracing.ErrSpanContextNotFound
+	for _, f := range propagationFormats(tracerName) {
+		p, ok := propagators[f]
+		if !ok {
+			continue
+		}
+		sc, e := p.extract(tracer, format, carrier)
+		if e == nil {
+			return sc, nil
+		}
+		if e != opentracing.ErrSpanContextNotFound && err == opentracing.ErrSpanContextNotFound {
+			err = e
+		}
+	}
+	return nil, err
+}
+
+// nativePropagator tracer 自身的格式
+
+type nativePropagator struct{}
+
+func (nativePropagator) inject(tracer opentracing.Tracer, sc opentracing.SpanContext, format, carrier interface{}) error {
+	return tracer.Inject(sc, format, carrier)
+}
+
+func (nativePropagator) extract(tracer opentracing.Tracer, format, carrier interface{}) (opentracing.SpanContext, error) {
+	return tracer.Extract(format, carrier)
+}
+
+// readCarrier 读出 carrier 中的所有 key/value ， key 转为小写
+func readCarrier(carrier interface{}) (map[string]string, error) {
+	r, ok := carrier.(opentracing.TextMapReader)
+	if !ok {
+		return nil, opentracing.ErrInvalidCarrier
+	}
+	m := make(map[string]string)
+	err := r.ForeachKey(func(key, val string) error {
+		m[strings.ToLower(key)] = val
+		return nil
+	})
+	return m, err
+}
+
+func carrierWriter(carrier interface{}) (opentracing.TextMapWriter, error) {
+	w, ok := carrier.(opentracing.TextMapWriter)
+	if !ok {
+		return nil, opentracing.ErrInvalidCarrier
+	}
+	return w, nil
+}
+
+func codecOf() (spanContextCodec, error) {
+	c, ok := DefaultTracer.(spanContextCodec)
+	if !ok {
+		return nil, opentracing.ErrUnsupportedFormat
+	}
+	return c, nil
+}
@@ -0,0 +1,162 @@
+package tracer
+
+import (
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+)
+
+// Zipkin B3
+//   多 header ： X-B3-TraceId 、 X-B3-SpanId 、 X-B3-ParentSpanId 、 X-B3-Sampled 、 X-B3-Flags
+//   单 header ： b3: {TraceId}-{SpanId}-{SamplingState}-{ParentSpanId}
+//   baggage ： baggage-{key}: {value} （两种格式相同）
+// 没有采样标记时（ defer ），由本地采样器决定是否采样
+// 与 DefaultTracer 使用哪个后端无关，只要后端支持 span context 与 trace id/span id 互转即可
+
+const (
+	b3TraceID       = "x-b3-traceid"
+	b3SpanID        = "x-b3-spanid"
+	b3ParentSpanID  = "x-b3-parentspanid"
+	b3Sampled       = "x-b3-sampled"
+	b3Flags         = "x-b3-flags"
+	b3Single        = "b3"
+	b3BaggagePrefix = "baggage-"
+)
+
+type b3Propagator struct{}
+
+func (b3Propagator) inject(tracer opentracing.Tracer, sc opentracing.SpanContext, format, carrier interface{}) error {
+	w, traceID, spanID, sampled, err := b3Prepare(sc, carrier)
+	if err != nil {
+		return err
+	}
+	w.Set(b3TraceID, traceID)
+	w.Set(b3SpanID, spanID)
+	if sampled {
+		w.Set(b3Sampled, "1")
+	} else {
+		w.Set(b3Sampled, "0")
+	}
+	b3InjectBaggage(w, sc)
+	return nil
+}
+
+func (b3Propagator) extract(tracer opentracing.Tracer, format, carrier interface{}) (opentracing.SpanContext, error) {
+	m, err := readCarrier(carrier)
+	if err != nil {
+		return nil, err
+	}
+	traceID, spanID := m[b3TraceID], m[b3SpanID]
+	if traceID == "" && spanID == "" {
+		return nil, opentracing.ErrSpanContextNotFound
+	}
+	var sampled *bool
+	switch {
+	case m[b3Flags] == "1", m[b3Sampled] == "1", m[b3Sampled] == "true":
+		sampled = b3Decision(true)
+	case m[b3Sampled] == "0", m[b3Sampled] == "false":
+		sampled = b3Decision(false)
+	}
+	return b3NewSpanContext(traceID, spanID, sampled, m)
+}
+
+type b3SinglePropagator struct{}
+
+func (b3SinglePropagator) inject(tracer opentracing.Tracer, sc opentracing.SpanContext, format, carrier interface{}) error {
+	w, traceID, spanID, sampled, err := b3Prepare(sc, carrier)
+	if err != nil {
+		return err
+	}
+	if sampled {
+		w.Set(b3Single, traceID+"-"+spanID+"-1")
+	} else {
+		w.Set(b3Single, traceID+"-"+spanID+"-0")
+	}
+	b3InjectBaggage(w, sc)
+	return nil
+}
+
+func (b3SinglePropagator) extract(tracer opentracing.Tracer, format, carrier interface{}) (opentracing.SpanContext, error) {
+	m, err := readCarrier(carrier)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := m[b3Single]
+	if !ok {
+		return nil, opentracing.ErrSpanContextNotFound
+	}
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) < 2 {
+		// 只有采样标记（ b3: 0 、 b3: 1 、 b3: d ），没有 span context
+		return nil, opentracing.ErrSpanContextNotFound
+	}
+	var sampled *bool
+	if len(parts) > 2 {
+		switch parts[2] {
+		case "1", "d":
+			sampled = b3Decision(true)
+		case "0":
+			sampled = b3Decision(false)
+		}
+	}
+	return b3NewSpanContext(parts[0], parts[1], sampled, m)
+}
+
+// b3Decision 调用方的采样决定
+func b3Decision(sampled bool) *bool {
+	return &sampled
+}
+
+func b3InjectBaggage(w opentracing.TextMapWriter, sc opentracing.SpanContext) {
+	sc.ForeachBaggageItem(func(k, v string) bool {
+		if k != tracestateBaggageKey {
+			w.Set(b3BaggagePrefix+k, v)
+		}
+		return true
+	})
+}
+
+func b3Prepare(sc opentracing.SpanContext, carrier interface{}) (w opentracing.TextMapWriter, traceID, spanID string, sampled bool, err error) {
+	codec, err := codecOf()
+	if err != nil {
+		return
+	}
+	if w, err = carrierWriter(carrier); err != nil {
+		return
+	}
+	traceID, spanID, sampled, ok := codec.SpanContextIDs(sc)
+	if !ok {
+		err = opentracing.ErrInvalidSpanContext
+		return
+	}
+	// 高 64 位为 0 时，使用 64 位 trace id ，兼容只支持 64 位的实现
+	if strings.HasPrefix(traceID, "0000000000000000") {
+		traceID = traceID[16:]
+	}
+	return
+}
+
+// b3NewSpanContext 构造 span context ， m 中 baggage- 开头的 header 做为 baggage
+func b3NewSpanContext(traceID, spanID string, sampled *bool, m map[string]string) (opentracing.SpanContext, error) {
+	traceID, spanID = strings.ToLower(traceID), strings.ToLower(spanID)
+	if (len(traceID) != 16 && len(traceID) != 32) || !isHex(traceID) || len(spanID) != 16 || !isHex(spanID) {
+		return nil, opentracing.ErrSpanContextCorrupted
+	}
+	if len(traceID) == 16 {
+		traceID = "0000000000000000" + traceID
+	}
+	codec, err := codecOf()
+	if err != nil {
+		return nil, err
+	}
+	var baggage map[string]string
+	for k, v := range m {
+		if strings.HasPrefix(k, b3BaggagePrefix) && len(k) > len(b3BaggagePrefix) {
+			if baggage == nil {
+				baggage = make(map[string]string)
+			}
+			baggage[k[len(b3BaggagePrefix):]] = v
+		}
+	}
+	return codec.NewSpanContext(traceID, spanID, sampled, baggage)
+}
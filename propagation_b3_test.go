@@ -0,0 +1,89 @@
+package tracer_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fananchong/tracer"
+	"github.com/fananchong/tracer/tracetest"
+	"github.com/opentracing/opentracing-go"
+)
+
+// TestB3Sampling 有采样标记时沿用调用方的决定，没有时（ defer ）由本地采样器决定
+func TestB3Sampling(t *testing.T) {
+	const traceID, spanID = "463ac35c9f6413ad48485a3953bb6124", "a2fb4a1d1a96d312"
+	tests := []struct {
+		name    string
+		header  map[string]string
+		rate    float64
+		sampled bool
+	}{
+		{"multi defer rate 1", map[string]string{"X-B3-TraceId": traceID, "X-B3-SpanId": spanID}, 1, true},
+		{"multi defer rate 0", map[string]string{"X-B3-TraceId": traceID, "X-B3-SpanId": spanID}, 0, false},
+		{"multi sampled", map[string]string{"X-B3-TraceId": traceID, "X-B3-SpanId": spanID, "X-B3-Sampled": "1"}, 0, true},
+		{"multi not sampled", map[string]string{"X-B3-TraceId": traceID, "X-B3-SpanId": spanID, "X-B3-Sampled": "0"}, 1, false},
+		{"multi debug", map[string]string{"X-B3-TraceId": traceID, "X-B3-SpanId": spanID, "X-B3-Flags": "1"}, 0, true},
+		{"single defer rate 1", map[string]string{"b3": traceID + "-" + spanID}, 1, true},
+		{"single defer rate 0", map[string]string{"b3": traceID + "-" + spanID}, 0, false},
+		{"single sampled", map[string]string{"b3": traceID + "-" + spanID + "-1"}, 0, true},
+		{"single not sampled", map[string]string{"b3": traceID + "-" + spanID + "-0"}, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := tracetest.Start(t, "b3")
+			tracer.SetOptions("b3", tracer.Options{
+				Propagation: []tracer.PropagationFormat{tracer.PropagationB3, tracer.PropagationB3Single},
+			})
+			if err := tracer.SetSamplingRate("b3", tt.rate); err != nil {
+				t.Fatal(err)
+			}
+			h := tracer.HTTPMiddleware("b3", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			req := httptest.NewRequest("GET", "/", nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			got := rec.Spans()
+			if sampled := len(got) == 1; sampled != tt.sampled {
+				t.Fatalf("got sampled %v, want %v", sampled, tt.sampled)
+			}
+			if tt.sampled && (got[0].TraceID != traceID || got[0].ParentID != spanID) {
+				t.Errorf("got trace %s parent %s", got[0].TraceID, got[0].ParentID)
+			}
+		})
+	}
+}
+
+// TestB3Baggage baggage-* header 与 baggage 互转
+func TestB3Baggage(t *testing.T) {
+	tracetest.Start(t, "b3")
+	tracer.SetOptions("b3", tracer.Options{Propagation: []tracer.PropagationFormat{tracer.PropagationB3}})
+
+	var got string
+	var out http.Header
+	h := tracer.HTTPMiddleware("b3", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span := opentracing.SpanFromContext(r.Context())
+		got = span.BaggageItem("user")
+		req, _ := http.NewRequestWithContext(r.Context(), "GET", "http://downstream/", nil)
+		client := &http.Client{Transport: tracer.HTTPTransport("b3", roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			out = r.Header
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: r}, nil
+		}))}
+		if resp, err := client.Do(req); err == nil {
+			resp.Body.Close()
+		}
+	}))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-B3-TraceId", "463ac35c9f6413ad48485a3953bb6124")
+	req.Header.Set("X-B3-SpanId", "a2fb4a1d1a96d312")
+	req.Header.Set("X-B3-Sampled", "1")
+	req.Header.Set("Baggage-User", "alice")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if got != "alice" {
+		t.Errorf("got baggage %q, want alice", got)
+	}
+	if v := out.Get("Baggage-User"); v != "alice" {
+		t.Errorf("got downstream header %v", out)
+	}
+}
@@ -0,0 +1,137 @@
+package tracer
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+)
+
+// W3C Trace Context
+//   traceparent: 00-{trace id 32 位十六进制}-{span id 16 位十六进制}-{flags}
+//   tracestate: 原样透传
+//   baggage: k1=v1,k2=v2 （ W3C Baggage ）
+// tracestate 以 baggage 的形式保存在 span context 中，随 trace 向下游传递
+
+const (
+	w3cTraceParent = "traceparent"
+	w3cTraceState  = "tracestate"
+	w3cBaggage     = "baggage"
+
+	// tracestateBaggageKey 保存 tracestate 的 baggage key
+	tracestateBaggageKey = "w3c-tracestate"
+)
+
+type w3cPropagator struct{}
+
+func (w3cPropagator) inject(tracer opentracing.Tracer, sc opentracing.SpanContext, format, carrier interface{}) error {
+	codec, err := codecOf()
+	if err != nil {
+		return err
+	}
+	w, err := carrierWriter(carrier)
+	if err != nil {
+		return err
+	}
+	traceID, spanID, sampled, ok := codec.SpanContextIDs(sc)
+	if !ok {
+		return opentracing.ErrInvalidSpanContext
+	}
+	flags := "00"
+	if sampled {
+		flags = "01"
+	}
+	w.Set(w3cTraceParent, "00-"+traceID+"-"+spanID+"-"+flags)
+
+	var baggage []string
+	sc.ForeachBaggageItem(func(k, v string) bool {
+		if k == tracestateBaggageKey {
+			w.Set(w3cTraceState, v)
+		} else {
+			baggage = append(baggage, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+		return true
+	})
+	if len(baggage) > 0 {
+		w.Set(w3cBaggage, strings.Join(baggage, ","))
+	}
+	return nil
+}
+
+func (w3cPropagator) extract(tracer opentracing.Tracer, format, carrier interface{}) (opentracing.SpanContext, error) {
+	m, err := readCarrier(carrier)
+	if err != nil {
+		return nil, err
+	}
+	traceparent, ok := m[w3cTraceParent]
+	if !ok {
+		return nil, opentracing.ErrSpanContextNotFound
+	}
+	traceID, spanID, sampled, ok := parseTraceParent(traceparent)
+	if !ok {
+		return nil, opentracing.ErrSpanContextCorrupted
+	}
+	codec, err := codecOf()
+	if err != nil {
+		return nil, err
+	}
+
+	baggage := make(map[string]string)
+	if v := m[w3cBaggage]; v != "" {
+		for _, item := range strings.Split(v, ",") {
+			// 忽略 ; 之后的属性
+			item = strings.TrimSpace(strings.SplitN(item, ";", 2)[0])
+			kv := strings.SplitN(item, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			k, err1 := url.QueryUnescape(strings.TrimSpace(kv[0]))
+			v, err2 := url.QueryUnescape(strings.TrimSpace(kv[1]))
+			if err1 == nil && err2 == nil && k != "" {
+				baggage[k] = v
+			}
+		}
+	}
+	if v := m[w3cTraceState]; v != "" {
+		baggage[tracestateBaggageKey] = v
+	}
+	return codec.NewSpanContext(traceID, spanID, &sampled, baggage)
+}
+
+// parseTraceParent 解析 traceparent
+func parseTraceParent(v string) (traceID, spanID string, sampled, ok bool) {
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return
+	}
+	// 版本 00 必须正好 4 段，更高版本允许后面有扩展字段
+	if parts[0] == "00" && len(parts) != 4 {
+		return
+	}
+	if !isHex(parts[0]) || len(parts[1]) != 32 || !isHex(parts[1]) || len(parts[2]) != 16 || !isHex(parts[2]) ||
+		len(parts[3]) != 2 || !isHex(parts[3]) {
+		return
+	}
+	if strings.Trim(parts[1], "0") == "" || strings.Trim(parts[2], "0") == "" {
+		return
+	}
+	flags := hexValue(parts[3][1])
+	return parts[1], parts[2], flags&1 == 1, true
+}
+
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func hexValue(c byte) byte {
+	if c >= 'a' {
+		return c - 'a' + 10
+	}
+	return c - '0'
+}
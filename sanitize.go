@@ -0,0 +1,245 @@
+package tracer
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// StatementMode db.statement 记录方式
+type StatementMode int
+
+const (
+	// StatementNormalized 规范化后记录，字面量替换为 ? （默认）
+	StatementNormalized StatementMode = iota
+	// StatementRaw 原样记录
+	StatementRaw
+)
+
+// RedactRule 参数脱敏规则
+// Name 不为空时，命名参数（ sql.Named ）名字相同则整个替换
+// Pattern 不为空时，替换字符串参数中匹配的部分
+type RedactRule struct {
+	Name        string
+	Pattern     *regexp.Regexp
+	Replacement string // 默认 ***
+}
+
+func (r *RedactRule) replacement() string {
+	if r.Replacement == "" {
+		return "***"
+	}
+	return r.Replacement
+}
+
+var (
+	reINList     = regexp.MustCompile(`(?i)\bIN\s*\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+	reValuesList = regexp.MustCompile(`(?i)\b(VALUES\s*\([^()]*\))(?:\s*,\s*\([^()]*\))+`)
+)
+
+// NormalizeSQL 规范化 SQL 语句
+// 字符串、数字字面量替换为 ? ， IN 列表、多行 VALUES 合并为一个，去掉注释，合并空白
+// 例如： SELECT * FROM t WHERE id IN (1, 2, 3) AND name = 'abc'
+// 结果： SELECT * FROM t WHERE id IN (?) AND name = ?
+// 双引号按 MySQL 默认的 sql_mode 视为字符串，反引号为标识符，原样保留
+func NormalizeSQL(query string) string {
+	return normalizeSQL(query, false)
+}
+
+// NormalizeSQLANSI 同 NormalizeSQL ，但双引号为标识符（ ANSI_QUOTES 、 PostgreSQL 等），原样保留
+func NormalizeSQLANSI(query string) string {
+	return normalizeSQL(query, true)
+}
+
+func normalizeSQL(query string, ansiQuotes bool) string {
+	var b strings.Builder
+	b.Grow(len(query))
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '\'' || (c == '"' && !ansiQuotes):
+			i = skipQuoted(query, i, c)
+			b.WriteByte('?')
+		case c == '"' || c == '`':
+			j := skipQuoted(query, i, c)
+			b.WriteString(query[i:j])
+			i = j
+		case c == '-' && i+1 < len(query) && query[i+1] == '-', c == '#':
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+			b.WriteByte(' ')
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			if j := strings.Index(query[i+2:], "*/"); j >= 0 {
+				i += j + 4
+			} else {
+				i = len(query)
+			}
+			b.WriteByte(' ')
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			b.WriteByte(' ')
+			i++
+		case isDigit(c) && (i == 0 || !isIdentChar(query[i-1])):
+			for i < len(query) && (isIdentChar(query[i]) || query[i] == '.') {
+				i++
+			}
+			b.WriteByte('?')
+		case isIdentChar(c) || c == '$' || c == ':' || c == '@':
+			// 标识符、占位符（ $1 、 :name 、 @p1 ）原样保留
+			j := i + 1
+			for j < len(query) && isIdentChar(query[j]) {
+				j++
+			}
+			b.WriteString(query[i:j])
+			i = j
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return collapseSQL(b.String())
+}
+
+// collapseSQL 合并空白、 IN 列表、多行 VALUES
+func collapseSQL(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	s = reINList.ReplaceAllString(s, "IN (?)")
+	s = reValuesList.ReplaceAllString(s, "$1")
+	return s
+}
+
+// skipQuoted 跳过引号包围的内容，返回结束引号之后的位置
+func skipQuoted(s string, i int, quote byte) int {
+	for i++; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case quote:
+			if i+1 < len(s) && s[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+// NormalizeRedis 规范化 Redis 命令，保留命令名和 key ，值替换为 ?
+// 例如： [set data TestRedis ex 60] 结果： [set data ? ? ?]
+func NormalizeRedis(args []interface{}) string {
+	if len(args) == 0 {
+		return "[]"
+	}
+	out := make([]interface{}, len(args))
+	out[0] = args[0]
+	name := strings.ToUpper(fmt.Sprint(args[0]))
+	for i := 1; i < len(args); i++ {
+		if redisIsKey(name, i) {
+			out[i] = args[i]
+		} else {
+			out[i] = "?"
+		}
+	}
+	return fmt.Sprintf("%v", out)
+}
+
+// redisIsKey 判断命令的第 i 个参数是否是 key （或 hash field 之类的结构名）
+func redisIsKey(name string, i int) bool {
+	switch name {
+	case "PING", "ECHO", "AUTH", "SELECT", "INFO", "CONFIG", "CLIENT", "EVAL", "EVALSHA", "PUBLISH":
+		return false
+	case "DEL", "UNLINK", "EXISTS", "TOUCH", "WATCH", "MGET", "SINTER", "SUNION", "SDIFF", "PFCOUNT",
+		"RENAME", "RENAMENX", "RPOPLPUSH", "KEYS", "TYPE", "SUBSCRIBE", "PSUBSCRIBE":
+		return true
+	case "MSET", "MSETNX":
+		return i%2 == 1
+	case "HSET", "HMSET", "HSETNX":
+		return i == 1 || i%2 == 0
+	case "HGET", "HDEL", "HEXISTS", "HMGET", "HSTRLEN":
+		return true
+	case "HINCRBY", "HINCRBYFLOAT":
+		// key field increment
+		return i <= 2
+	}
+	return i == 1
+}
+
+// sqlStatement 按选项生成 db.statement
+func sqlStatement(o *SQLOptions, query string) string {
+	if o.Statement == StatementRaw {
+		return query
+	}
+	return normalizeSQL(query, o.ANSIQuotes)
+}
+
+// redisStatement 按选项生成 db.statement
+func redisStatement(o *RedisOptions, args []interface{}) string {
+	if o.Statement == StatementRaw {
+		return fmt.Sprintf("%v", args)
+	}
+	return NormalizeRedis(args)
+}
+
+// redactStatement 对完整语句应用脱敏规则（只使用 Pattern 规则）
+func redactStatement(rules []RedactRule, statement string) string {
+	for i := range rules {
+		if rule := &rules[i]; rule.Pattern != nil {
+			statement = rule.Pattern.ReplaceAllString(statement, rule.replacement())
+		}
+	}
+	return statement
+}
+
+// sqlFullStatement 慢查询记录的完整语句：原始语句按 Redact 脱敏（不规范化），打开 LogParams 时带上绑定参数（已脱敏）
+func sqlFullStatement(o *SQLOptions, query string, args []driver.NamedValue) string {
+	statement := redactStatement(o.Redact, query)
+	if o.LogParams && len(args) > 0 {
+		statement += " " + sqlParams(o, args)
+	}
+	return statement
+}
+
+// redisFullStatement 慢命令记录的完整命令：原始命令按 Redact 脱敏（不规范化）
+func redisFullStatement(o *RedisOptions, args []interface{}) string {
+	return redactStatement(o.Redact, fmt.Sprintf("%v", args))
+}
+
+// sqlParams 按脱敏规则格式化绑定参数
+func sqlParams(o *SQLOptions, args []driver.NamedValue) string {
+	params := make([]string, len(args))
+	for i, arg := range args {
+		params[i] = redactParam(o.Redact, arg.Name, arg.Value)
+	}
+	return "[" + strings.Join(params, ", ") + "]"
+}
+
+func redactParam(rules []RedactRule, name string, value interface{}) string {
+	if b, ok := value.([]byte); ok {
+		value = string(b)
+	}
+	s := fmt.Sprintf("%v", value)
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Name != "" && rule.Name == name {
+			s = rule.replacement()
+			break
+		}
+		if _, ok := value.(string); ok && rule.Pattern != nil {
+			s = rule.Pattern.ReplaceAllString(s, rule.replacement())
+		}
+	}
+	if name != "" {
+		return name + "=" + s
+	}
+	return s
+}
@@ -0,0 +1,69 @@
+package tracer
+
+import (
+	"testing"
+)
+
+func TestNormalizeSQL(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"SELECT * FROM t WHERE id = 1", "SELECT * FROM t WHERE id = ?"},
+		{"SELECT * FROM t WHERE name = 'alice'", "SELECT * FROM t WHERE name = ?"},
+		{`SELECT * FROM t WHERE name = "alice"`, "SELECT * FROM t WHERE name = ?"},
+		{`SELECT * FROM t WHERE name = "a\"b" AND x = 'it''s'`, "SELECT * FROM t WHERE name = ? AND x = ?"},
+		{"SELECT `name` FROM `user` WHERE id = ?", "SELECT `name` FROM `user` WHERE id = ?"},
+		{"SELECT * FROM t WHERE id IN (1, 2, 3)", "SELECT * FROM t WHERE id IN (?)"},
+		{"INSERT INTO t (a, b) VALUES (1, 'x'), (2, 'y')", "INSERT INTO t (a, b) VALUES (?, ?)"},
+		{"SELECT a -- comment\nFROM t /* c */ WHERE b = 2.5 # tail", "SELECT a FROM t WHERE b = ?"},
+		{"SELECT t1.c2 FROM t1 WHERE c2 = $1 AND c3 = :name AND c4 = @p1", "SELECT t1.c2 FROM t1 WHERE c2 = $1 AND c3 = :name AND c4 = @p1"},
+		{"SELECT   *\n\tFROM t", "SELECT * FROM t"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeSQL(tt.query); got != tt.want {
+			t.Errorf("NormalizeSQL(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeSQLANSI(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`SELECT "name" FROM "user" WHERE id = 1`, `SELECT "name" FROM "user" WHERE id = ?`},
+		{`SELECT * FROM t WHERE name = 'alice'`, `SELECT * FROM t WHERE name = ?`},
+	}
+	for _, tt := range tests {
+		if got := NormalizeSQLANSI(tt.query); got != tt.want {
+			t.Errorf("NormalizeSQLANSI(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeRedis(t *testing.T) {
+	tests := []struct {
+		args []interface{}
+		want string
+	}{
+		{nil, "[]"},
+		{[]interface{}{"get", "data"}, "[get data]"},
+		{[]interface{}{"set", "data", "secret", "ex", 60}, "[set data ? ? ?]"},
+		{[]interface{}{"auth", "password"}, "[auth ?]"},
+		{[]interface{}{"del", "a", "b"}, "[del a b]"},
+		{[]interface{}{"mset", "a", 1, "b", 2}, "[mset a ? b ?]"},
+		{[]interface{}{"hset", "h", "f1", "v1", "f2", "v2"}, "[hset h f1 ? f2 ?]"},
+		{[]interface{}{"hget", "h", "f"}, "[hget h f]"},
+		{[]interface{}{"hincrby", "h", "f", 10}, "[hincrby h f ?]"},
+		{[]interface{}{"HINCRBYFLOAT", "h", "f", 1.5}, "[HINCRBYFLOAT h f ?]"},
+		{[]interface{}{"incrby", "counter", 5}, "[incrby counter ?]"},
+		{[]interface{}{"zadd", "z", 1, "m"}, "[zadd z ? ?]"},
+		{[]interface{}{"publish", "channel", "message"}, "[publish ? ?]"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeRedis(tt.args); got != tt.want {
+			t.Errorf("NormalizeRedis(%v) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+//go:build !windows
+// +build !windows
+
+package tracer
+
+import (
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc/grpclog"
+)
+
+// HandleSignals 收到 SIGUSR1 时，打开 tracer ， window 时间后自动关闭（同 EnableFor ）；收到 SIGUSR2 时，立即关闭 tracer
+// names 为空时，作用于所有登记过、创建过的 tracer （见 Names 、 Register ）。返回的函数停止处理信号
+// 结果通过 grpclog 输出
+//
+//	kill -USR1 <pid>  # 打开 10 分钟
+//	kill -USR2 <pid>  # 提前关闭
+//
+// Windows 不支持，调用无效果
+func HandleSignals(window time.Duration, names ...string) (stop func()) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGUSR1, syscall.SIGUSR2)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-c:
+				handleSignal(sig, window, names)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(c)
+		close(done)
+	}
+}
+
+func handleSignal(sig os.Signal, window time.Duration, names []string) {
+	if len(names) == 0 {
+		names = Names()
+	}
+	switch sig {
+	case syscall.SIGUSR1:
+		for _, name := range names {
+			if err := EnableFor(name, window); err != nil {
+				grpclog.Errorf("tracer %s enable error! %s", name, err.Error())
+			}
+		}
+		grpclog.Infof("tracer enabled for %s: %s", window, strings.Join(names, ", "))
+	case syscall.SIGUSR2:
+		for _, name := range names {
+			Disable(name)
+		}
+		grpclog.Infof("tracer disabled: %s", strings.Join(names, ", "))
+	}
+}
@@ -0,0 +1,40 @@
+//go:build !windows
+// +build !windows
+
+package tracer_test
+
+import (
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/fananchong/tracer"
+	"github.com/fananchong/tracer/tracetest"
+)
+
+// waitEnabled 等待 tracer 打开或关闭
+func waitEnabled(name string, enabled bool) bool {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if (tracer.Get(name) != nil) == enabled {
+			return true
+		}
+	}
+	return false
+}
+
+// TestHandleSignals SIGUSR1 打开登记过的 tracer ， SIGUSR2 关闭
+func TestHandleSignals(t *testing.T) {
+	tracetest.Start(t)
+	tracer.Register("signal1")
+	stop := tracer.HandleSignals(time.Hour)
+	defer stop()
+
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+	if !waitEnabled("signal1", true) {
+		t.Fatal("SIGUSR1 did not enable the registered tracer")
+	}
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR2)
+	if !waitEnabled("signal1", false) {
+		t.Fatal("SIGUSR2 did not disable the tracer")
+	}
+}
@@ -0,0 +1,10 @@
+package tracer
+
+import (
+	"time"
+)
+
+// HandleSignals Windows 没有 SIGUSR1 、 SIGUSR2 ，调用无效果
+func HandleSignals(window time.Duration, names ...string) (stop func()) {
+	return func() {}
+}
@@ -0,0 +1,57 @@
+package tracer
+
+import (
+	"runtime/debug"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+// SlowOptions 慢操作阈值
+type SlowOptions struct {
+	// Threshold 超过该耗时的操作，span 带上 slow=true 、完整语句（原始语句按 Redact 脱敏， SQL 打开 LogParams 时带上绑定参数）、调用栈。
+	// 0 表示不检查
+	Threshold time.Duration
+	// ForceSample 慢操作强制采样，避免被采样器丢弃
+	ForceSample bool
+}
+
+// spanTags 开始 span 时设置的 tag ，强制采样后重新设置
+type spanTags []opentracing.Tag
+
+// Apply 实现 opentracing.StartSpanOption
+func (tags spanTags) Apply(o *opentracing.StartSpanOptions) {
+	for _, tag := range tags {
+		tag.Apply(o)
+	}
+}
+
+// forceSample 强制采样 span ，并重新设置开始时的 tag
+// jaeger 丢弃未采样 span 的 tag 、 log ，强制采样前设置的 tag 已经丢失
+func forceSample(span opentracing.Span, tags spanTags) {
+	ext.SamplingPriority.Set(span, 1)
+	for _, tag := range tags {
+		tag.Set(span)
+	}
+}
+
+// markSlow 操作耗时超过阈值时，标记 span
+// tags 为开始 span 时设置的 tag ，强制采样时重新设置； statement 是惰性生成的完整语句，只在慢操作时调用
+func markSlow(span opentracing.Span, tags spanTags, o *SlowOptions, elapsed time.Duration, statement func() string) {
+	if o.Threshold <= 0 || elapsed < o.Threshold {
+		return
+	}
+	// jaeger 丢弃未采样 span 的 tag 、 log ，先设置采样优先级
+	if o.ForceSample {
+		forceSample(span, tags)
+	}
+	span.SetTag("slow", true)
+	span.SetTag("db.statement.full", statement())
+	span.LogFields(
+		log.String("event", "slow"),
+		log.String("elapsed", elapsed.String()),
+		log.String("stack", string(debug.Stack())),
+	)
+}
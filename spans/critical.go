@@ -0,0 +1,144 @@
+package spans
+
+import (
+	"sort"
+	"time"
+)
+
+// CriticalPath trace 的关键路径：决定 trace 总耗时的 span 链
+// 从根 span 的结束时间往前，每次选择最晚结束的子 span ，递归进入，直到根 span 的开始时间
+// 子 span 之间的空隙，以及没有子 span 覆盖的时间，计入父 span 自身
+type CriticalPath struct {
+	Root     *Span
+	Duration time.Duration
+	Segments []CriticalSegment // 关键路径上的时间段，按时间排序
+	Spans    []*CriticalSpan   // 关键路径上的 span ，按树的先序排列
+}
+
+// CriticalSegment 关键路径上的一段时间，该时间段内 Span 自身在执行（没有等待关键路径上的子 span ）
+type CriticalSegment struct {
+	Span  *Span
+	Start time.Time
+	End   time.Time
+}
+
+// CriticalSpan 关键路径上的 span
+type CriticalSpan struct {
+	Span     *Span
+	Depth    int
+	SelfTime time.Duration // span 耗时中，没有子 span 覆盖的时间
+	Critical time.Duration // 计入关键路径的时间
+	Percent  float64       // Critical 占 trace 总耗时的百分比
+}
+
+// CriticalPath 计算关键路径
+// 有多个根 span 时，取耗时最长的根 span
+func (t *Trace) CriticalPath() *CriticalPath {
+	roots := t.Tree()
+	if len(roots) == 0 {
+		return nil
+	}
+	root := roots[0]
+	for _, n := range roots[1:] {
+		if n.Span.Duration > root.Span.Duration {
+			root = n
+		}
+	}
+	cp := &CriticalPath{Root: root.Span, Duration: root.Span.Duration}
+	criticalSegments(root, root.Span.Start, root.Span.End(), &cp.Segments)
+	for i, j := 0, len(cp.Segments)-1; i < j; i, j = i+1, j-1 {
+		cp.Segments[i], cp.Segments[j] = cp.Segments[j], cp.Segments[i]
+	}
+
+	critical := make(map[*Span]time.Duration)
+	for _, seg := range cp.Segments {
+		critical[seg.Span] += seg.End.Sub(seg.Start)
+	}
+	var walk func(n *Node, depth int) bool
+	walk = func(n *Node, depth int) bool {
+		cs := &CriticalSpan{Span: n.Span, Depth: depth, SelfTime: selfTime(n), Critical: critical[n.Span]}
+		if cp.Duration > 0 {
+			cs.Percent = float64(cs.Critical) * 100 / float64(cp.Duration)
+		}
+		i := len(cp.Spans)
+		cp.Spans = append(cp.Spans, cs)
+		onPath := cs.Critical > 0
+		for _, c := range n.Children {
+			if walk(c, depth+1) {
+				onPath = true
+			}
+		}
+		if !onPath {
+			cp.Spans = cp.Spans[:i]
+		}
+		return onPath
+	}
+	walk(root, 0)
+	return cp
+}
+
+// criticalSegments 计算节点在 [start, end] 内的关键路径，时间段按倒序追加到 out
+func criticalSegments(n *Node, start, end time.Time, out *[]CriticalSegment) {
+	cursor := end
+	for cursor.After(start) {
+		// 选择在 cursor 之前开始、最晚结束的子 span
+		var next *Node
+		var nextEnd time.Time
+		for _, c := range n.Children {
+			if !c.Span.Start.Before(cursor) || !c.Span.End().After(start) {
+				continue
+			}
+			e := minTime(c.Span.End(), cursor)
+			if next == nil || e.After(nextEnd) || e.Equal(nextEnd) && c.Span.Duration > next.Span.Duration {
+				next, nextEnd = c, e
+			}
+		}
+		if next == nil {
+			*out = append(*out, CriticalSegment{Span: n.Span, Start: start, End: cursor})
+			return
+		}
+		if nextEnd.Before(cursor) {
+			*out = append(*out, CriticalSegment{Span: n.Span, Start: nextEnd, End: cursor})
+		}
+		nextStart := maxTime(next.Span.Start, start)
+		criticalSegments(next, nextStart, nextEnd, out)
+		cursor = nextStart
+	}
+}
+
+// selfTime span 耗时中，没有子 span 覆盖的时间
+func selfTime(n *Node) time.Duration {
+	start, end := n.Span.Start, n.Span.End()
+	covered := time.Duration(0)
+	cursor := start
+	children := append([]*Node(nil), n.Children...)
+	sortNodes(children)
+	for _, c := range children {
+		s, e := maxTime(c.Span.Start, cursor), minTime(c.Span.End(), end)
+		if e.After(s) {
+			covered += e.Sub(s)
+			cursor = e
+		}
+	}
+	return n.Span.Duration - covered
+}
+
+func sortNodes(nodes []*Node) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].Span.Start.Before(nodes[j].Span.Start)
+	})
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
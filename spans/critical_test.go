@@ -0,0 +1,76 @@
+package spans
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCriticalPath(t *testing.T) {
+	at := func(ms int) time.Time { return time.Unix(0, 0).Add(time.Duration(ms) * time.Millisecond) }
+	span := func(id, parent string, start, end int) *Span {
+		return &Span{SpanID: id, ParentID: parent, Operation: id, Start: at(start), Duration: time.Duration(end-start) * time.Millisecond}
+	}
+	tests := []struct {
+		name     string
+		spans    []*Span
+		root     string
+		path     []string // 关键路径上的 span ：操作名 深度 计入关键路径的时间 百分比 自身时间
+		segments []string // 关键路径上的时间段（毫秒）
+	}{
+		{"sequential children", []*Span{
+			span("a", "", 0, 100), span("b", "a", 10, 40), span("c", "a", 50, 90),
+		}, "a", []string{
+			"a 0 30ms 30.0% 30ms", "b 1 30ms 30.0% 30ms", "c 1 40ms 40.0% 40ms",
+		}, []string{"a 0-10", "b 10-40", "a 40-50", "c 50-90", "a 90-100"}},
+		{"parallel children", []*Span{
+			span("a", "", 0, 100), span("b", "a", 10, 60), span("c", "a", 20, 80),
+		}, "a", []string{
+			"a 0 30ms 30.0% 30ms", "b 1 10ms 10.0% 50ms", "c 1 60ms 60.0% 60ms",
+		}, []string{"a 0-10", "b 10-20", "c 20-80", "a 80-100"}},
+		{"gaps between children", []*Span{
+			span("a", "", 0, 100), span("b", "a", 20, 30), span("c", "a", 60, 70),
+		}, "a", []string{
+			"a 0 80ms 80.0% 80ms", "b 1 10ms 10.0% 10ms", "c 1 10ms 10.0% 10ms",
+		}, []string{"a 0-20", "b 20-30", "a 30-60", "c 60-70", "a 70-100"}},
+		{"child past parent end", []*Span{
+			span("a", "", 0, 100), span("b", "a", 50, 150), span("c", "b", 120, 140),
+		}, "a", []string{
+			"a 0 50ms 50.0% 50ms", "b 1 50ms 50.0% 80ms",
+		}, []string{"a 0-50", "b 50-100"}},
+		{"multiple roots", []*Span{
+			span("x", "", 0, 50), span("y", "", 10, 210), span("z", "y", 20, 120),
+		}, "y", []string{
+			"y 0 100ms 50.0% 100ms", "z 1 100ms 50.0% 100ms",
+		}, []string{"y 10-20", "z 20-120", "y 120-210"}},
+		{"nested", []*Span{
+			span("a", "", 0, 100), span("b", "a", 0, 100), span("c", "b", 40, 60),
+		}, "a", []string{
+			"a 0 0s 0.0% 0s", "b 1 80ms 80.0% 80ms", "c 2 20ms 20.0% 20ms",
+		}, []string{"b 0-40", "c 40-60", "b 60-100"}},
+	}
+	for _, tt := range tests {
+		cp := (&Trace{ID: "1", Spans: tt.spans}).CriticalPath()
+		if cp.Root.Operation != tt.root {
+			t.Errorf("%s: got root %s, want %s", tt.name, cp.Root.Operation, tt.root)
+		}
+		var got []string
+		for _, s := range cp.Spans {
+			got = append(got, fmt.Sprintf("%s %d %s %.1f%% %s", s.Span.Operation, s.Depth, s.Critical, s.Percent, s.SelfTime))
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.path, "\n") {
+			t.Errorf("%s: got spans\n%s\nwant\n%s", tt.name, strings.Join(got, "\n"), strings.Join(tt.path, "\n"))
+		}
+		got = nil
+		for _, seg := range cp.Segments {
+			got = append(got, fmt.Sprintf("%s %d-%d", seg.Span.Operation, seg.Start.Sub(at(0)).Milliseconds(), seg.End.Sub(at(0)).Milliseconds()))
+		}
+		if strings.Join(got, " ") != strings.Join(tt.segments, " ") {
+			t.Errorf("%s: got segments %v, want %v", tt.name, got, tt.segments)
+		}
+	}
+	if cp := (&Trace{ID: "1"}).CriticalPath(); cp != nil {
+		t.Errorf("got %+v for an empty trace", cp)
+	}
+}
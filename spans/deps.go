@@ -0,0 +1,229 @@
+package spans
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Graph 由 span 统计出的服务依赖图
+type Graph struct {
+	Nodes []*GraphNode `json:"nodes"`
+	Edges []*Edge      `json:"edges"`
+}
+
+// 节点类型
+const (
+	NodeService   = "service"   // 上报 span 的服务
+	NodeComponent = "component" // 没有上报 span 的下游，如 redis 、 MySQL
+)
+
+// GraphNode 依赖图的节点
+type GraphNode struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+}
+
+// Edge 依赖图的边，调用方 From 调用被调方 To
+type Edge struct {
+	From      string        `json:"from"`
+	To        string        `json:"to"`
+	Component string        `json:"component"` // HTTP 、 gRPC 、 redis 、 MySQL 等
+	Calls     int           `json:"calls"`
+	Errors    int           `json:"errors"`
+	ErrorRate float64       `json:"error_rate"`
+	P50       time.Duration `json:"p50"`
+	P99       time.Duration `json:"p99"`
+	Cycle     bool          `json:"cycle"` // 是否在调用环上
+
+	durations []time.Duration
+}
+
+// Dependencies 统计服务依赖图
+// 父 span 与子 span 的服务不同时，记为父服务调用子服务，耗时取子 span 的耗时；
+// 带 db.type 的 span 记为服务调用 db.type 对应的组件；
+// 没有下游 span 的客户端 span ，按 peer.service 、 peer.address 、 message_bus.destination 记为调用组件
+func Dependencies(traces []*Trace) *Graph {
+	g := &Graph{}
+	nodes := make(map[string]*GraphNode)
+	edges := make(map[[3]string]*Edge)
+	addNode := func(name, kind string) {
+		if n, ok := nodes[name]; !ok {
+			n = &GraphNode{Name: name, Kind: kind}
+			nodes[name] = n
+			g.Nodes = append(g.Nodes, n)
+		} else if kind == NodeService {
+			n.Kind = kind
+		}
+	}
+	addCall := func(from, to, component string, s *Span) {
+		key := [3]string{from, to, component}
+		e, ok := edges[key]
+		if !ok {
+			e = &Edge{From: from, To: to, Component: component}
+			edges[key] = e
+			g.Edges = append(g.Edges, e)
+		}
+		e.Calls++
+		if s.Error() {
+			e.Errors++
+		}
+		e.durations = append(e.durations, s.Duration)
+	}
+
+	for _, t := range traces {
+		byID := make(map[string]*Span, len(t.Spans))
+		for _, s := range t.Spans {
+			byID[s.SpanID] = s
+		}
+		remote := make(map[string]bool) // 有其他服务子 span 的 span
+		for _, s := range t.Spans {
+			addNode(s.Service, NodeService)
+			if p, ok := byID[ParentOf(s)]; ok && p.Service != s.Service {
+				remote[p.SpanID] = true
+				addCall(p.Service, s.Service, componentOf(s, p), s)
+			}
+		}
+		for _, s := range t.Spans {
+			if remote[s.SpanID] {
+				continue
+			}
+			to := downstreamOf(s)
+			if to == "" {
+				continue
+			}
+			addNode(to, NodeComponent)
+			addCall(s.Service, to, componentOf(s, nil), s)
+		}
+	}
+
+	for _, e := range g.Edges {
+		e.ErrorRate = float64(e.Errors) / float64(e.Calls)
+		sort.Slice(e.durations, func(i, j int) bool { return e.durations[i] < e.durations[j] })
+		e.P50 = percentile(e.durations, 50)
+		e.P99 = percentile(e.durations, 99)
+		e.durations = nil
+	}
+	markCycles(g)
+	sort.Slice(g.Nodes, func(i, j int) bool { return g.Nodes[i].Name < g.Nodes[j].Name })
+	sort.Slice(g.Edges, func(i, j int) bool {
+		a, b := g.Edges[i], g.Edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.To != b.To {
+			return a.To < b.To
+		}
+		return a.Component < b.Component
+	})
+	return g
+}
+
+// componentOf 调用的组件，取被调 span 的 component ，没有时取调用 span 的
+func componentOf(s, caller *Span) string {
+	if c := s.Tag("component"); c != "" {
+		return c
+	}
+	if caller != nil {
+		if c := caller.Tag("component"); c != "" {
+			return c
+		}
+	}
+	return s.Tag("db.type")
+}
+
+// downstreamOf 没有上报 span 的下游
+func downstreamOf(s *Span) string {
+	if t := s.Tag("db.type"); t != "" {
+		return t
+	}
+	switch s.Tag("span.kind") {
+	case "client", "producer":
+		for _, key := range []string{"peer.service", "peer.address", "message_bus.destination"} {
+			if v := s.Tag(key); v != "" {
+				return v
+			}
+		}
+	}
+	return ""
+}
+
+// percentile 最近秩法，durations 已排序
+func percentile(durations []time.Duration, p int) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	i := (len(durations)*p+99)/100 - 1
+	if i < 0 {
+		i = 0
+	}
+	return durations[i]
+}
+
+// markCycles 标记调用环上的边： To 能到达 From
+func markCycles(g *Graph) {
+	out := make(map[string][]string)
+	for _, e := range g.Edges {
+		out[e.From] = append(out[e.From], e.To)
+	}
+	reach := func(from, to string) bool {
+		seen := map[string]bool{from: true}
+		stack := []string{from}
+		for len(stack) > 0 {
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if n == to {
+				return true
+			}
+			for _, m := range out[n] {
+				if !seen[m] {
+					seen[m] = true
+					stack = append(stack, m)
+				}
+			}
+		}
+		return false
+	}
+	for _, e := range g.Edges {
+		e.Cycle = reach(e.To, e.From)
+	}
+}
+
+// WriteJSON 以 JSON 格式输出
+func (g *Graph) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
+}
+
+// WriteDOT 以 Graphviz DOT 格式输出
+// 出错的边为红色，调用环上的边加粗
+func (g *Graph) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph dependencies {\n\trankdir=LR;\n\tnode [fontname=\"Helvetica\"];\n")
+	for _, n := range g.Nodes {
+		shape := "box"
+		if n.Kind == NodeComponent {
+			shape = "cylinder"
+		}
+		fmt.Fprintf(&b, "\t%q [shape=%s];\n", n.Name, shape)
+	}
+	for _, e := range g.Edges {
+		label := fmt.Sprintf("%s\\n%d calls, %.1f%% errors\\np50 %s, p99 %s",
+			e.Component, e.Calls, e.ErrorRate*100, e.P50.Round(time.Microsecond), e.P99.Round(time.Microsecond))
+		attrs := fmt.Sprintf("label=\"%s\"", strings.Replace(label, "\"", "\\\"", -1))
+		if e.Errors > 0 {
+			attrs += ", color=red, fontcolor=red"
+		}
+		if e.Cycle {
+			attrs += ", style=bold, penwidth=2"
+		}
+		fmt.Fprintf(&b, "\t%q -> %q [%s];\n", e.From, e.To, attrs)
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
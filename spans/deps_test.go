@@ -0,0 +1,166 @@
+package spans
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDependencies(t *testing.T) {
+	// span id 父 id 服务 耗时（毫秒） tag
+	span := func(id, parent, service string, ms int, tags ...string) *Span {
+		s := &Span{SpanID: id, ParentID: parent, Service: service, Duration: time.Duration(ms) * time.Millisecond, Tags: map[string]interface{}{}}
+		for i := 0; i+1 < len(tags); i += 2 {
+			s.Tags[tags[i]] = tags[i+1]
+		}
+		return s
+	}
+	trace := func(spans ...*Span) *Trace { return &Trace{Spans: spans} }
+	tests := []struct {
+		name   string
+		traces []*Trace
+		nodes  []string // 名字 类型
+		edges  []string // from->to 组件 调用次数 错误次数 错误率 p50 p99 是否在环上
+	}{
+		{"service to service", []*Trace{
+			trace(span("1", "", "web", 100), span("2", "1", "api", 40, "component", "gRPC")),
+			trace(span("1", "", "web", 100, "component", "HTTP"), span("2", "1", "api", 60)),
+		}, []string{"api service", "web service"}, []string{
+			"web->api HTTP 1 0 0.00 60ms 60ms false",
+			"web->api gRPC 1 0 0.00 40ms 40ms false",
+		}},
+		{"same service is not an edge", []*Trace{
+			trace(span("1", "", "web", 100), span("2", "1", "web", 40)),
+		}, []string{"web service"}, nil},
+		{"db and redis components", []*Trace{
+			trace(span("1", "", "api", 100),
+				span("2", "1", "api", 10, "component", "database/sql", "db.type", "sql"),
+				span("3", "1", "api", 2, "component", "redis", "db.type", "redis"),
+				span("4", "1", "api", 4, "db.type", "redis")),
+		}, []string{"api service", "redis component", "sql component"}, []string{
+			"api->redis redis 2 0 0.00 2ms 4ms false", // 没有 component 时取 db.type
+			"api->sql database/sql 1 0 0.00 10ms 10ms false",
+		}},
+		{"peer downstream", []*Trace{
+			trace(span("1", "", "api", 100),
+				span("2", "1", "api", 5, "span.kind", "client", "component", "HTTP", "peer.service", "billing", "peer.address", "10.0.0.1:80"),
+				span("3", "1", "api", 6, "span.kind", "client", "component", "HTTP", "peer.address", "10.0.0.2:80"),
+				span("4", "1", "api", 7, "span.kind", "producer", "component", "kafka", "message_bus.destination", "orders"),
+				span("5", "1", "api", 8, "span.kind", "server", "peer.address", "10.0.0.3:80")),
+		}, []string{"10.0.0.2:80 component", "api service", "billing component", "orders component"}, []string{
+			"api->10.0.0.2:80 HTTP 1 0 0.00 6ms 6ms false",
+			"api->billing HTTP 1 0 0.00 5ms 5ms false",
+			"api->orders kafka 1 0 0.00 7ms 7ms false",
+		}},
+		{"peer with reported downstream", []*Trace{
+			trace(span("1", "", "web", 100, "span.kind", "client", "component", "gRPC", "peer.service", "api"),
+				span("2", "1", "api", 50, "span.kind", "server")),
+		}, []string{"api service", "web service"}, []string{
+			"web->api gRPC 1 0 0.00 50ms 50ms false",
+		}},
+		{"error rate and percentiles", []*Trace{
+			trace(span("1", "", "web", 100), span("2", "1", "api", 10, "component", "gRPC", "error", "true")),
+			trace(span("1", "", "web", 100), span("2", "1", "api", 40, "component", "gRPC")),
+			trace(span("1", "", "web", 100), span("2", "1", "api", 20, "component", "gRPC", "error", "true")),
+			trace(span("1", "", "web", 100), span("2", "1", "api", 30, "component", "gRPC")),
+		}, []string{"api service", "web service"}, []string{
+			"web->api gRPC 4 2 0.50 20ms 40ms false",
+		}},
+		{"two node cycle", []*Trace{
+			trace(span("1", "", "a", 100), span("2", "1", "b", 50, "component", "gRPC"), span("3", "2", "a", 20, "component", "gRPC")),
+		}, []string{"a service", "b service"}, []string{
+			"a->b gRPC 1 0 0.00 50ms 50ms true",
+			"b->a gRPC 1 0 0.00 20ms 20ms true",
+		}},
+		{"three node cycle", []*Trace{
+			trace(span("1", "", "a", 100),
+				span("2", "1", "b", 50, "component", "gRPC"),
+				span("3", "2", "c", 30, "component", "gRPC"),
+				span("4", "3", "a", 10, "component", "gRPC"),
+				span("5", "1", "d", 5, "component", "gRPC")),
+		}, []string{"a service", "b service", "c service", "d service"}, []string{
+			"a->b gRPC 1 0 0.00 50ms 50ms true",
+			"a->d gRPC 1 0 0.00 5ms 5ms false",
+			"b->c gRPC 1 0 0.00 30ms 30ms true",
+			"c->a gRPC 1 0 0.00 10ms 10ms true",
+		}},
+	}
+	for _, tt := range tests {
+		g := Dependencies(tt.traces)
+		var nodes, edges []string
+		for _, n := range g.Nodes {
+			nodes = append(nodes, n.Name+" "+n.Kind)
+		}
+		for _, e := range g.Edges {
+			edges = append(edges, fmt.Sprintf("%s->%s %s %d %d %.2f %s %s %v",
+				e.From, e.To, e.Component, e.Calls, e.Errors, e.ErrorRate, e.P50, e.P99, e.Cycle))
+		}
+		if strings.Join(nodes, "\n") != strings.Join(tt.nodes, "\n") {
+			t.Errorf("%s: got nodes\n%s\nwant\n%s", tt.name, strings.Join(nodes, "\n"), strings.Join(tt.nodes, "\n"))
+		}
+		if strings.Join(edges, "\n") != strings.Join(tt.edges, "\n") {
+			t.Errorf("%s: got edges\n%s\nwant\n%s", tt.name, strings.Join(edges, "\n"), strings.Join(tt.edges, "\n"))
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	ms := func(values ...int) []time.Duration {
+		var d []time.Duration
+		for _, v := range values {
+			d = append(d, time.Duration(v)*time.Millisecond)
+		}
+		return d
+	}
+	hundred := make([]int, 100)
+	for i := range hundred {
+		hundred[i] = i + 1
+	}
+	tests := []struct {
+		name      string
+		durations []time.Duration
+		p50, p99  time.Duration
+	}{
+		{"empty", nil, 0, 0},
+		{"single sample", ms(7), 7 * time.Millisecond, 7 * time.Millisecond},
+		{"two samples", ms(1, 9), 1 * time.Millisecond, 9 * time.Millisecond},
+		{"odd count", ms(1, 2, 3, 4, 5), 3 * time.Millisecond, 5 * time.Millisecond},
+		{"hundred samples", ms(hundred...), 50 * time.Millisecond, 99 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := percentile(tt.durations, 50); got != tt.p50 {
+			t.Errorf("%s: got p50 %s, want %s", tt.name, got, tt.p50)
+		}
+		if got := percentile(tt.durations, 99); got != tt.p99 {
+			t.Errorf("%s: got p99 %s, want %s", tt.name, got, tt.p99)
+		}
+	}
+}
+
+func TestMarkCycles(t *testing.T) {
+	tests := []struct {
+		name  string
+		edges []string // from->to
+		cycle []bool
+	}{
+		{"chain", []string{"a->b", "b->c"}, []bool{false, false}},
+		{"two nodes", []string{"a->b", "b->a"}, []bool{true, true}},
+		{"three nodes", []string{"a->b", "b->c", "c->a", "c->d"}, []bool{true, true, true, false}},
+		{"self call", []string{"a->a"}, []bool{true}},
+		{"diamond", []string{"a->b", "a->c", "b->d", "c->d"}, []bool{false, false, false, false}},
+	}
+	for _, tt := range tests {
+		g := &Graph{}
+		for _, e := range tt.edges {
+			ft := strings.Split(e, "->")
+			g.Edges = append(g.Edges, &Edge{From: ft[0], To: ft[1]})
+		}
+		markCycles(g)
+		for i, e := range g.Edges {
+			if e.Cycle != tt.cycle[i] {
+				t.Errorf("%s: %s->%s got cycle %v, want %v", tt.name, e.From, e.To, e.Cycle, tt.cycle[i])
+			}
+		}
+	}
+}
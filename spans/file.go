@@ -0,0 +1,179 @@
+package spans
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/grpclog"
+)
+
+// fileFlushInterval FileWriter 后台写入文件的间隔
+const fileFlushInterval = time.Second
+
+// fileBufferSize FileWriter 的缓冲区大小，写满时立即写入文件
+const fileBufferSize = 64 << 10
+
+// fileRotateRetry 滚动失败后，过多久再重试
+var fileRotateRetry = time.Second
+
+// FileWriter 把 span 按 JSON lines 格式写入文件，文件超过大小时滚动
+// 滚动时 path 改名为 path.1 ， path.1 改名为 path.2 ，依此类推，最多保留 maxBackups 个
+// span 先写入内存缓冲区，后台每秒写入文件一次（缓冲区满时立即写入）， span 结束时不等待磁盘 IO ；
+// Close 时写入剩余的 span
+// 滚动失败（改名、打开新文件出错）时继续写入当前文件，稍后重试滚动
+type FileWriter struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+
+	mu   sync.Mutex
+	f    *os.File
+	buf  *bufio.Writer
+	size int64 // 文件大小，包含缓冲区中还没写入的部分
+
+	renamed bool      // 当前文件已改名，还没打开新文件
+	retryAt time.Time // 滚动失败后，下次重试的时间
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// NewFileWriter FileWriter 构造函数
+// maxBytes 为 0 时不滚动
+func NewFileWriter(path string, maxBytes int64, maxBackups int) (*FileWriter, error) {
+	w := &FileWriter{
+		path:       path,
+		maxBytes:   maxBytes,
+		maxBackups: maxBackups,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	go w.loop()
+	return w, nil
+}
+
+func (w *FileWriter) open() error {
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.f, w.size = f, info.Size()
+	if w.buf == nil {
+		w.buf = bufio.NewWriterSize(f, fileBufferSize)
+	} else {
+		w.buf.Reset(f)
+	}
+	return nil
+}
+
+// WriteSpan 实现 Sink
+func (w *FileWriter) WriteSpan(span *Span) {
+	line, err := json.Marshal(span)
+	if err != nil {
+		grpclog.Errorf("cannot marshal span: %s", err.Error())
+		return
+	}
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return
+	}
+	full := w.maxBytes > 0 && w.size > 0 && w.size+int64(len(line)) > w.maxBytes
+	if (full || w.renamed) && !time.Now().Before(w.retryAt) {
+		if err = w.rotate(); err != nil {
+			grpclog.Errorf("cannot rotate span file, keep writing to the current file: %s", err.Error())
+			w.retryAt = time.Now().Add(fileRotateRetry)
+		}
+	}
+	n, err := w.buf.Write(line)
+	w.size += int64(n)
+	if err != nil {
+		grpclog.Errorf("cannot write span file: %s", err.Error())
+	}
+}
+
+// Flush 把缓冲区中的 span 写入文件
+func (w *FileWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return nil
+	}
+	return w.buf.Flush()
+}
+
+func (w *FileWriter) loop() {
+	defer close(w.done)
+	ticker := time.NewTicker(fileFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := w.Flush(); err != nil {
+				grpclog.Errorf("cannot write span file: %s", err.Error())
+			}
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// rotate 滚动文件，出错时当前文件保持打开
+// 改名成功、打开新文件失败时，记下 renamed ，重试时只打开新文件
+func (w *FileWriter) rotate() error {
+	if err := w.buf.Flush(); err != nil {
+		return err
+	}
+	if !w.renamed {
+		if w.maxBackups > 0 {
+			for i := w.maxBackups - 1; i > 0; i-- {
+				os.Rename(fmt.Sprintf("%s.%d", w.path, i), fmt.Sprintf("%s.%d", w.path, i+1))
+			}
+			if err := os.Rename(w.path, w.path+".1"); err != nil {
+				return err
+			}
+		} else if err := os.Remove(w.path); err != nil {
+			return err
+		}
+		w.renamed = true
+	}
+	old := w.f
+	if err := w.open(); err != nil {
+		return err
+	}
+	old.Close()
+	w.renamed = false
+	return nil
+}
+
+// Close 写入缓冲区中的 span ，关闭文件
+func (w *FileWriter) Close() error {
+	w.once.Do(func() { close(w.stop) })
+	<-w.done
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return nil
+	}
+	err := w.buf.Flush()
+	if e := w.f.Close(); err == nil {
+		err = e
+	}
+	w.f = nil
+	return err
+}
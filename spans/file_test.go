@@ -0,0 +1,98 @@
+package spans
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	w, err := NewFileWriter(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		w.WriteSpan(&Span{TraceID: "00000000000000000000000000000001", SpanID: "0000000000000001", Operation: "op", Start: start})
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[0].Operation != "op" || !got[0].Start.Equal(start) {
+		t.Fatalf("got %+v", got)
+	}
+}
+
+func TestFileWriterRotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	w, err := NewFileWriter(path, 200, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		w.WriteSpan(&Span{TraceID: "00000000000000000000000000000001", SpanID: "0000000000000001", Operation: "op"})
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() > 200 {
+			t.Errorf("%s: size %d > 200", p, info.Size())
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("want at most 2 backups, got %v", err)
+	}
+}
+
+// TestFileWriterRotateFail 滚动失败时继续写入当前文件，之后重试滚动
+func TestFileWriterRotateFail(t *testing.T) {
+	defer func(d time.Duration) { fileRotateRetry = d }(fileRotateRetry)
+	fileRotateRetry = 0
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	// path.1 是非空目录，改名失败
+	if err := os.MkdirAll(filepath.Join(path+".1", "x"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewFileWriter(path, 200, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	span := &Span{TraceID: "00000000000000000000000000000001", SpanID: "0000000000000001", Operation: "op"}
+	for i := 0; i < 10; i++ {
+		w.WriteSpan(span)
+	}
+	if err = w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ReadFile(path); err != nil || len(got) != 10 {
+		t.Fatalf("got %d spans, %v, want 10 in the current file", len(got), err)
+	}
+
+	if err := os.RemoveAll(path + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	w.WriteSpan(span)
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ReadFile(path + ".1"); err != nil || len(got) != 10 {
+		t.Errorf("got %d spans, %v, want 10 in the backup", len(got), err)
+	}
+	if got, err := ReadFile(path); err != nil || len(got) != 1 {
+		t.Errorf("got %d spans, %v, want 1 after rotating", len(got), err)
+	}
+}
@@ -0,0 +1,62 @@
+package spans
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Filter trace 过滤条件， tracecat 、 tracebrowser 共用
+// 零值匹配所有 trace
+type Filter struct {
+	Service     string        // 含有该服务的 span
+	Operation   string        // 含有操作名包含该字符串的 span （与 Service 是同一个 span ）
+	TraceID     string        // trace id 前缀
+	MinDuration time.Duration // 总耗时不小于该值
+	Errors      bool          // 含有出错的 span
+}
+
+// Match trace 是否满足过滤条件
+func (f *Filter) Match(t *Trace) bool {
+	if f.TraceID != "" && !strings.HasPrefix(t.ID, f.TraceID) {
+		return false
+	}
+	if f.MinDuration > 0 && t.Duration() < f.MinDuration {
+		return false
+	}
+	if f.Errors && !t.HasError() {
+		return false
+	}
+	if f.Service == "" && f.Operation == "" {
+		return true
+	}
+	for _, s := range t.Spans {
+		if (f.Service == "" || s.Service == f.Service) && (f.Operation == "" || strings.Contains(s.Operation, f.Operation)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply 返回满足过滤条件的 trace ，保持原来的顺序
+func (f *Filter) Apply(traces []*Trace) []*Trace {
+	var result []*Trace
+	for _, t := range traces {
+		if f.Match(t) {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// FormatDuration 按量级格式化耗时： 1.23s 、 4.56ms 、 7.8µs
+func FormatDuration(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return fmt.Sprintf("%.2fs", d.Seconds())
+	case d >= time.Millisecond:
+		return fmt.Sprintf("%.2fms", float64(d)/float64(time.Millisecond))
+	default:
+		return fmt.Sprintf("%.1fµs", float64(d)/float64(time.Microsecond))
+	}
+}
@@ -0,0 +1,55 @@
+package spans
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFilterMatch(t *testing.T) {
+	start := time.Unix(0, 0)
+	trace := &Trace{ID: "abc123", Spans: []*Span{
+		{Service: "gate", Operation: "HTTP GET /login", Start: start, Duration: 100 * time.Millisecond},
+		{Service: "db", Operation: "SQL QUERY", Start: start, Duration: 10 * time.Millisecond, Tags: map[string]interface{}{"error": true}},
+	}}
+	tests := []struct {
+		f    Filter
+		want bool
+	}{
+		{Filter{}, true},
+		{Filter{Service: "gate"}, true},
+		{Filter{Service: "other"}, false},
+		{Filter{Operation: "login"}, true},
+		{Filter{Service: "db", Operation: "login"}, false},
+		{Filter{Service: "db", Operation: "SQL"}, true},
+		{Filter{TraceID: "abc"}, true},
+		{Filter{TraceID: "123"}, false},
+		{Filter{MinDuration: 100 * time.Millisecond}, true},
+		{Filter{MinDuration: 101 * time.Millisecond}, false},
+		{Filter{Errors: true}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.f.Match(trace); got != tt.want {
+			t.Errorf("%+v: got %v, want %v", tt.f, got, tt.want)
+		}
+	}
+	if got := (&Filter{Errors: true}).Apply([]*Trace{{ID: "ok"}, trace}); len(got) != 1 || got[0] != trace {
+		t.Errorf("Apply got %v", got)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{1500 * time.Millisecond, "1.50s"},
+		{2500 * time.Microsecond, "2.50ms"},
+		{1500 * time.Nanosecond, "1.5µs"},
+		{0, "0.0µs"},
+	}
+	for _, tt := range tests {
+		if got := FormatDuration(tt.d); got != tt.want {
+			t.Errorf("FormatDuration(%s) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
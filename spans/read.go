@@ -0,0 +1,158 @@
+package spans
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Read 读取 span
+// 支持两种格式：
+//   - JSON lines ，每行一个 Span （ UseJSONLines 、 FileWriter 写入的文件）
+//   - jaeger JSON （ jaeger UI 下载的 JSON 、 /api/traces 的应答）
+func Read(r io.Reader) ([]*Span, error) {
+	var spans []*Span
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err == io.EOF {
+			return spans, nil
+		} else if err != nil {
+			return spans, err
+		}
+		var probe map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &probe); err != nil {
+			return spans, err
+		}
+		switch {
+		case probe["data"] != nil:
+			var doc struct {
+				Data []jaegerTrace `json:"data"`
+			}
+			if err := unmarshal(raw, &doc); err != nil {
+				return spans, err
+			}
+			for i := range doc.Data {
+				spans = append(spans, doc.Data[i].spans()...)
+			}
+		case probe["spans"] != nil:
+			var t jaegerTrace
+			if err := unmarshal(raw, &t); err != nil {
+				return spans, err
+			}
+			spans = append(spans, t.spans()...)
+		default:
+			s := &Span{}
+			if err := unmarshal(raw, s); err != nil {
+				return spans, err
+			}
+			spans = append(spans, s)
+		}
+	}
+}
+
+// ReadFile 读取文件中的 span ，格式同 Read
+func ReadFile(path string) ([]*Span, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	spans, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err.Error())
+	}
+	return spans, nil
+}
+
+func unmarshal(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// jaeger JSON 格式，时间单位为微秒
+
+type jaegerTrace struct {
+	TraceID   string       `json:"traceID"`
+	Spans     []jaegerSpan `json:"spans"`
+	Processes map[string]struct {
+		ServiceName string `json:"serviceName"`
+	} `json:"processes"`
+}
+
+type jaegerSpan struct {
+	TraceID       string `json:"traceID"`
+	SpanID        string `json:"spanID"`
+	OperationName string `json:"operationName"`
+	References    []struct {
+		RefType string `json:"refType"`
+		TraceID string `json:"traceID"`
+		SpanID  string `json:"spanID"`
+	} `json:"references"`
+	StartTime int64            `json:"startTime"`
+	Duration  int64            `json:"duration"`
+	Tags      []jaegerKeyValue `json:"tags"`
+	Logs      []struct {
+		Timestamp int64            `json:"timestamp"`
+		Fields    []jaegerKeyValue `json:"fields"`
+	} `json:"logs"`
+	ProcessID string `json:"processID"`
+}
+
+type jaegerKeyValue struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+func (t *jaegerTrace) spans() []*Span {
+	spans := make([]*Span, 0, len(t.Spans))
+	for i := range t.Spans {
+		js := &t.Spans[i]
+		s := &Span{
+			TraceID:   js.TraceID,
+			SpanID:    js.SpanID,
+			Service:   t.Processes[js.ProcessID].ServiceName,
+			Operation: js.OperationName,
+			Start:     time.Unix(0, js.StartTime*int64(time.Microsecond)),
+			Duration:  time.Duration(js.Duration) * time.Microsecond,
+			Tags:      keyValues(js.Tags),
+		}
+		if s.TraceID == "" {
+			s.TraceID = t.TraceID
+		}
+		for _, ref := range js.References {
+			typ := "child_of"
+			if ref.RefType == "FOLLOWS_FROM" {
+				typ = "follows_from"
+			}
+			s.References = append(s.References, Reference{Type: typ, TraceID: ref.TraceID, SpanID: ref.SpanID})
+			if typ == "child_of" && s.ParentID == "" && ref.TraceID == s.TraceID {
+				s.ParentID = ref.SpanID
+			}
+		}
+		for _, l := range js.Logs {
+			s.Logs = append(s.Logs, Log{
+				Timestamp: time.Unix(0, l.Timestamp*int64(time.Microsecond)),
+				Fields:    keyValues(l.Fields),
+			})
+		}
+		spans = append(spans, s)
+	}
+	return spans
+}
+
+func keyValues(kvs []jaegerKeyValue) map[string]interface{} {
+	if len(kvs) == 0 {
+		return nil
+	}
+	m := make(map[string]interface{}, len(kvs))
+	for _, kv := range kvs {
+		m[kv.Key] = kv.Value
+	}
+	return m
+}
@@ -0,0 +1,65 @@
+// Package spans 已结束 span 的数据结构，用于导出、离线分析
+package spans
+
+import (
+	"fmt"
+	"time"
+)
+
+// Span 已结束 span 的快照
+type Span struct {
+	TraceID    string                 `json:"trace_id"`
+	SpanID     string                 `json:"span_id"`
+	ParentID   string                 `json:"parent_id,omitempty"`
+	References []Reference            `json:"references,omitempty"`
+	Service    string                 `json:"service"`
+	Operation  string                 `json:"operation"`
+	Start      time.Time              `json:"start"`
+	Duration   time.Duration          `json:"duration"`
+	Tags       map[string]interface{} `json:"tags,omitempty"`
+	Logs       []Log                  `json:"logs,omitempty"`
+}
+
+// Reference span 之间的引用
+type Reference struct {
+	Type    string `json:"type"` // child_of 或 follows_from
+	TraceID string `json:"trace_id"`
+	SpanID  string `json:"span_id"`
+}
+
+// Log span 上的日志
+type Log struct {
+	Timestamp time.Time              `json:"timestamp"`
+	Fields    map[string]interface{} `json:"fields"`
+}
+
+// End 结束时间
+func (s *Span) End() time.Time {
+	return s.Start.Add(s.Duration)
+}
+
+// Tag 获取 tag 的字符串值，不存在时返回空字符串
+func (s *Span) Tag(key string) string {
+	if v, ok := s.Tags[key]; ok {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+// Error span 是否出错（ error=true ）
+func (s *Span) Error() bool {
+	return s.Tag("error") == "true"
+}
+
+// Sink 接收已结束的 span
+type Sink interface {
+	WriteSpan(span *Span)
+}
+
+// SinkFunc 函数形式的 Sink
+type SinkFunc func(span *Span)
+
+// WriteSpan 实现 Sink
+func (f SinkFunc) WriteSpan(span *Span) {
+	f(span)
+}
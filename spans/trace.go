@@ -0,0 +1,157 @@
+package spans
+
+import (
+	"sort"
+	"time"
+)
+
+// Trace 同一个 trace id 的所有 span
+type Trace struct {
+	ID    string
+	Spans []*Span
+}
+
+// Node span 树的节点
+type Node struct {
+	Span     *Span
+	Children []*Node
+}
+
+// Group 按 trace id 分组，返回的 trace 按开始时间排序
+func Group(spans []*Span) []*Trace {
+	index := make(map[string]*Trace)
+	var traces []*Trace
+	for _, s := range spans {
+		t, ok := index[s.TraceID]
+		if !ok {
+			t = &Trace{ID: s.TraceID}
+			index[s.TraceID] = t
+			traces = append(traces, t)
+		}
+		t.Spans = append(t.Spans, s)
+	}
+	for _, t := range traces {
+		sortSpans(t.Spans)
+	}
+	sort.SliceStable(traces, func(i, j int) bool {
+		return traces[i].Start().Before(traces[j].Start())
+	})
+	return traces
+}
+
+// Start trace 的开始时间
+func (t *Trace) Start() time.Time {
+	var start time.Time
+	for i, s := range t.Spans {
+		if i == 0 || s.Start.Before(start) {
+			start = s.Start
+		}
+	}
+	return start
+}
+
+// End trace 的结束时间
+func (t *Trace) End() time.Time {
+	var end time.Time
+	for _, s := range t.Spans {
+		if s.End().After(end) {
+			end = s.End()
+		}
+	}
+	return end
+}
+
+// Duration trace 的总耗时
+func (t *Trace) Duration() time.Duration {
+	return t.End().Sub(t.Start())
+}
+
+// HasError trace 中是否有出错的 span
+func (t *Trace) HasError() bool {
+	for _, s := range t.Spans {
+		if s.Error() {
+			return true
+		}
+	}
+	return false
+}
+
+// Tree 构造 span 树，返回根节点
+// 父 span 为 parent id ，没有 parent id 时取第一个引用（ FollowsFrom ）
+// 父 span 不在 trace 中（例如没有上报）的 span 也做为根节点
+// span id 重复时，子 span 挂在第一个 span 下；父子关系成环时，环中最早开始的 span 做为根节点
+// 每个 span 在树中只出现一次，子节点按开始时间排序
+func (t *Trace) Tree() []*Node {
+	all := make([]*Node, len(t.Spans))
+	nodes := make(map[string]*Node, len(t.Spans))
+	for i, s := range t.Spans {
+		all[i] = &Node{Span: s}
+		if _, ok := nodes[s.SpanID]; !ok {
+			nodes[s.SpanID] = all[i]
+		}
+	}
+	parents := make(map[*Node]*Node, len(all))
+	var roots []*Node
+	for _, n := range all {
+		if p, ok := nodes[ParentOf(n.Span)]; ok && p != n {
+			p.Children = append(p.Children, n)
+			parents[n] = p
+		} else {
+			roots = append(roots, n)
+		}
+	}
+	// 从根节点不能到达的 span 在环中：断开与父 span 的连接，做为根节点
+	visited := make(map[*Node]bool, len(all))
+	for _, root := range roots {
+		markVisited(root, visited)
+	}
+	cut := false
+	for _, n := range all {
+		if visited[n] {
+			continue
+		}
+		p := parents[n]
+		for i, c := range p.Children {
+			if c == n {
+				p.Children = append(p.Children[:i:i], p.Children[i+1:]...)
+				break
+			}
+		}
+		roots = append(roots, n)
+		markVisited(n, visited)
+		cut = true
+	}
+	if cut {
+		sortNodes(roots)
+	}
+	return roots
+}
+
+func markVisited(n *Node, visited map[*Node]bool) {
+	if visited[n] {
+		return
+	}
+	visited[n] = true
+	for _, c := range n.Children {
+		markVisited(c, visited)
+	}
+}
+
+// ParentOf 获取 span 的父 span id
+func ParentOf(s *Span) string {
+	if s.ParentID != "" {
+		return s.ParentID
+	}
+	for _, ref := range s.References {
+		if ref.TraceID == s.TraceID || ref.TraceID == "" {
+			return ref.SpanID
+		}
+	}
+	return ""
+}
+
+func sortSpans(spans []*Span) {
+	sort.SliceStable(spans, func(i, j int) bool {
+		return spans[i].Start.Before(spans[j].Start)
+	})
+}
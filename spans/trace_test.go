@@ -0,0 +1,86 @@
+package spans
+
+import (
+	"testing"
+	"time"
+)
+
+// countNodes 统计树中的节点，同一个 span 出现多次时返回 false
+func countNodes(roots []*Node) (int, bool) {
+	seen := map[*Span]bool{}
+	ok := true
+	var walk func(n *Node)
+	walk = func(n *Node) {
+		if seen[n.Span] {
+			ok = false
+			return
+		}
+		seen[n.Span] = true
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	for _, r := range roots {
+		walk(r)
+	}
+	return len(seen), ok
+}
+
+func TestTreeMalformed(t *testing.T) {
+	at := func(ms int) time.Time { return time.Unix(0, 0).Add(time.Duration(ms) * time.Millisecond) }
+	tests := []struct {
+		name  string
+		spans []*Span
+		roots []string // 根节点的操作名
+	}{
+		{"normal", []*Span{
+			{SpanID: "1", Operation: "a", Start: at(0)},
+			{SpanID: "2", ParentID: "1", Operation: "b", Start: at(1)},
+		}, []string{"a"}},
+		{"duplicate id", []*Span{
+			{SpanID: "1", Operation: "a", Start: at(0)},
+			{SpanID: "2", ParentID: "1", Operation: "b", Start: at(1)},
+			{SpanID: "2", ParentID: "1", Operation: "b2", Start: at(2)},
+			{SpanID: "3", ParentID: "2", Operation: "c", Start: at(3)},
+		}, []string{"a"}},
+		{"self parent", []*Span{
+			{SpanID: "1", ParentID: "1", Operation: "a", Start: at(0)},
+		}, []string{"a"}},
+		{"cycle", []*Span{
+			{SpanID: "1", ParentID: "3", Operation: "a", Start: at(0)},
+			{SpanID: "2", ParentID: "1", Operation: "b", Start: at(1)},
+			{SpanID: "3", ParentID: "2", Operation: "c", Start: at(2)},
+		}, []string{"a"}},
+		{"cycle and root", []*Span{
+			{SpanID: "9", Operation: "root", Start: at(0)},
+			{SpanID: "1", ParentID: "2", Operation: "a", Start: at(1)},
+			{SpanID: "2", ParentID: "1", Operation: "b", Start: at(2)},
+		}, []string{"root", "a"}},
+		{"duplicate id cycle", []*Span{
+			{SpanID: "1", ParentID: "2", Operation: "a", Start: at(0)},
+			{SpanID: "2", ParentID: "1", Operation: "b", Start: at(1)},
+			{SpanID: "1", ParentID: "2", Operation: "a2", Start: at(2)},
+		}, []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roots := (&Trace{Spans: tt.spans}).Tree()
+			n, ok := countNodes(roots)
+			if !ok || n != len(tt.spans) {
+				t.Errorf("tree has %d spans (unique %v), want %d", n, ok, len(tt.spans))
+			}
+			var got []string
+			for _, r := range roots {
+				got = append(got, r.Span.Operation)
+			}
+			if len(got) != len(tt.roots) {
+				t.Fatalf("got roots %v, want %v", got, tt.roots)
+			}
+			for i := range got {
+				if got[i] != tt.roots[i] {
+					t.Fatalf("got roots %v, want %v", got, tt.roots)
+				}
+			}
+		})
+	}
+}
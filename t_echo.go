@@ -1,47 +1,58 @@
 package tracer
 
 import (
+	"context"
+	"net/http"
+
 	"github.com/labstack/echo/v4"
 	"github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/ext"
-	"github.com/opentracing/opentracing-go/log"
 )
 
 // EchoMiddleware echo 的中间件
 func EchoMiddleware(tracerName string) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			if tracer := Get(tracerName); tracer != nil {
+			if tracer := Get(tracerName); tracer != nil && !skipped(tracerName, c.Request().URL.Path) {
 				r := c.Request()
 
 				carrier := opentracing.HTTPHeadersCarrier(r.Header)
-				spanContext, err := tracer.Extract(opentracing.HTTPHeaders, carrier)
+				spanContext, err := extractFrom(tracerName, tracer, opentracing.HTTPHeaders, carrier)
 				if err != nil && err != opentracing.ErrSpanContextNotFound {
 					// 如果 tracer extract 失败，那么跳过追踪
 					c.Logger().Errorf("SpanContext Extract Error! %s", err.Error())
 					return next(c)
 				}
-				span := tracer.StartSpan(
-					"HTTP "+r.Method+" "+r.URL.Path,
-					ext.RPCServerOption(spanContext),
-					opentracing.Tag{Key: string(ext.Component), Value: "HTTP"},
-				)
+				operationName := "HTTP " + r.Method + " " + r.URL.Path
+				tags := httpServerTags(r)
+				span := tracer.StartSpan(operationName, ext.RPCServerOption(spanContext), tags)
 				defer span.Finish()
+				defer watchSpan(tracerName, span, tags)()
 
-				ext.HTTPMethod.Set(span, r.Method)
-				ext.HTTPUrl.Set(span, r.URL.String())
-
-				r = r.WithContext(opentracing.ContextWithSpan(r.Context(), span))
+				ctx, cancel := httpServerDeadline(r, tracerName, span)
+				defer cancel()
+				r = r.WithContext(opentracing.ContextWithSpan(ctx, span))
 				c.SetRequest(r)
 
-				if err = tracer.Inject(span.Context(), opentracing.HTTPHeaders, carrier); err != nil {
-					span.LogFields(log.String("event", "Tracer.Inject() failed"), log.Error(err))
+				if h := getOptions(tracerName).HTTP.TraceIDHeader; h != "" {
+					c.Response().Header().Set(h, traceIDOf(tracer, span.Context()))
 				}
 
-				if err = next(c); err != nil {
-					ext.Error.Set(span, true)
-					span.LogFields(log.String("event", "error"), log.String("message", err.Error()))
+				if err = injectFault(ctx, tracerName, span, faultHTTP, r.URL.Path); err != nil {
+					err = echo.NewHTTPError(http.StatusServiceUnavailable, err.Error())
+				} else {
+					doWithProfileLabels(r.Context(), tracerName, span, operationName, func(ctx context.Context) {
+						if ctx != r.Context() {
+							c.SetRequest(r.WithContext(ctx))
+						}
+						err = next(c)
+					})
+				}
+				if err != nil {
+					setSpanError(r.Context(), tracerName, span, err)
 					c.Error(err)
+				} else if ctxErr := r.Context().Err(); ctxErr != nil {
+					setSpanError(r.Context(), tracerName, span, ctxErr)
 				}
 				ext.HTTPStatusCode.Set(span, uint16(c.Response().Status))
 				return err
@@ -0,0 +1,57 @@
+package tracer_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fananchong/tracer"
+	"github.com/fananchong/tracer/tracetest"
+	"github.com/labstack/echo/v4"
+)
+
+// TestEchoMiddlewareRequestHeader 不修改请求头；应答头中的 trace id 与 span 一致
+func TestEchoMiddlewareRequestHeader(t *testing.T) {
+	rec := tracetest.Start(t, "echo")
+	tracer.SetOptions("echo", tracer.Options{HTTP: tracer.HTTPOptions{TraceIDHeader: "X-Trace-Id"}})
+
+	e := echo.New()
+	e.Use(tracer.EchoMiddleware("echo"))
+	e.GET("/hello", func(c echo.Context) error {
+		if len(c.Request().Header) != 0 {
+			t.Errorf("request header modified: %v", c.Request().Header)
+		}
+		return c.String(http.StatusOK, "hello")
+	})
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, httptest.NewRequest("GET", "/hello", nil))
+
+	got := rec.Spans()
+	if len(got) != 1 || got[0].Operation != "HTTP GET /hello" || got[0].Tag("http.status_code") != "200" {
+		t.Fatalf("got %+v", got)
+	}
+	if id := w.Header().Get("X-Trace-Id"); id == "" || id != got[0].TraceID {
+		t.Errorf("got X-Trace-Id %q, want %s", id, got[0].TraceID)
+	}
+}
+
+// TestEchoMiddlewareProfileLabels 打开 Profile.Labels 时， handler 在 pprof.Do 中执行
+func TestEchoMiddlewareProfileLabels(t *testing.T) {
+	rec := tracetest.Start(t, "echo")
+	tracer.SetOptions("echo", tracer.Options{Profile: tracer.ProfileOptions{Labels: true}})
+
+	var labels map[string]string
+	e := echo.New()
+	e.Use(tracer.EchoMiddleware("echo"))
+	e.GET("/hello", func(c echo.Context) error {
+		labels = profileLabels(c.Request().Context())
+		return c.String(http.StatusOK, "hello")
+	})
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/hello", nil))
+
+	got := rec.Spans()
+	if len(got) != 1 {
+		t.Fatalf("got %d spans, want 1", len(got))
+	}
+	checkProfileLabels(t, labels, "echo", "HTTP GET /hello", got[0])
+}
@@ -0,0 +1,141 @@
+package tracer
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+	"google.golang.org/grpc/grpclog"
+)
+
+// Go 启动 goroutine 执行 fn
+// 新 goroutine 中创建 FollowsFrom ctx 中 span 的新 span ，并通过 ctx 传给 fn
+// ctx 中没有 span 时（ tracer 未打开），不创建 span
+// fn 中的 panic 记录到 span 上、写入日志后，继续 panic （与直接使用 go 语句一样，进程退出）
+func Go(ctx context.Context, name string, fn func(ctx context.Context)) {
+	goSpan(ctx, name, fn, false)
+}
+
+// GoRecover 同 Go ，但 fn 中的 panic 记录到 span 上、写入日志后，不再继续 panic
+func GoRecover(ctx context.Context, name string, fn func(ctx context.Context)) {
+	goSpan(ctx, name, fn, true)
+}
+
+func goSpan(ctx context.Context, name string, fn func(ctx context.Context), recovered bool) {
+	go func() {
+		span, ctx := startGoroutineSpan(ctx, name, opentracing.FollowsFrom)
+		if span != nil {
+			defer span.Finish()
+		}
+		defer func() {
+			if x := recover(); x != nil {
+				stack := debug.Stack()
+				grpclog.Errorf("goroutine %s panic: %v\n%s", name, x, stack)
+				if span != nil {
+					setPanicError(span, fmt.Errorf("panic: %v", x), stack)
+				}
+				if !recovered {
+					panic(x)
+				}
+			}
+		}()
+		fn(ctx)
+	}()
+}
+
+// startGoroutineSpan 以 ctx 中的 span 为父 span ，创建新 span
+func startGoroutineSpan(ctx context.Context, name string, ref func(opentracing.SpanContext) opentracing.SpanReference) (opentracing.Span, context.Context) {
+	parent := opentracing.SpanFromContext(ctx)
+	if parent == nil {
+		return nil, ctx
+	}
+	span := parent.Tracer().StartSpan(
+		name,
+		ref(parent.Context()),
+		opentracing.Tag{Key: string(ext.Component), Value: "goroutine"},
+	)
+	return span, opentracing.ContextWithSpan(ctx, span)
+}
+
+func setPanicError(span opentracing.Span, err error, stack []byte) {
+	ext.Error.Set(span, true)
+	span.SetTag("panic", true)
+	span.LogFields(
+		log.String("event", "error"),
+		log.String("message", err.Error()),
+		log.String("stack", string(stack)),
+	)
+}
+
+// Group 带追踪的 errgroup.Group
+// 每个 Go 调用创建 ChildOf 父 span 的子 span （互为兄弟 span ），任一子任务失败时，父 span 标记为 error
+type Group struct {
+	ctx    context.Context
+	cancel func()
+	parent opentracing.Span
+
+	wg      sync.WaitGroup
+	errOnce sync.Once
+	err     error
+}
+
+// NewGroup Group 构造函数
+// 与 errgroup.WithContext 一样，返回的 ctx 在第一个子任务失败或 Wait 返回时取消
+func NewGroup(ctx context.Context) (*Group, context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Group{
+		ctx:    ctx,
+		cancel: cancel,
+		parent: opentracing.SpanFromContext(ctx),
+	}, ctx
+}
+
+// Go 启动 goroutine 执行 fn ， fn 中的 panic 转换为错误（调用栈记录在 span 上）
+func (g *Group) Go(name string, fn func(ctx context.Context) error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		span, ctx := startGoroutineSpan(g.ctx, name, opentracing.ChildOf)
+		if span != nil {
+			defer span.Finish()
+		}
+		panicked := false
+		err := func() (err error) {
+			defer func() {
+				if x := recover(); x != nil {
+					// 调用栈只记录在 span 上，不放到返回给调用方的错误中
+					err, panicked = fmt.Errorf("panic: %v", x), true
+					if span != nil {
+						setPanicError(span, err, debug.Stack())
+					}
+				}
+			}()
+			return fn(ctx)
+		}()
+		if err != nil {
+			if span != nil && !panicked {
+				ext.Error.Set(span, true)
+				span.LogFields(log.String("event", "error"), log.String("message", err.Error()))
+			}
+			g.errOnce.Do(func() {
+				g.err = err
+				g.cancel()
+			})
+		}
+	}()
+}
+
+// Wait 等待所有子任务结束，返回第一个错误
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.cancel()
+	if g.err != nil && g.parent != nil {
+		ext.Error.Set(g.parent, true)
+		g.parent.LogFields(log.String("event", "error"), log.String("message", g.err.Error()))
+	}
+	return g.err
+}
@@ -0,0 +1,78 @@
+package tracer_test
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fananchong/tracer"
+	"github.com/fananchong/tracer/tracetest"
+)
+
+// startRoot 创建根 span ，返回带有根 span 的 ctx 和结束根 span 的函数
+func startRoot(t *testing.T, tracerName, operation string) (context.Context, func()) {
+	t.Helper()
+	span := tracer.Get(tracerName).StartSpan(operation)
+	return opentracingContext(span), span.Finish
+}
+
+func TestGoRecover(t *testing.T) {
+	rec := tracetest.Start(t, "go")
+	ctx, finish := startRoot(t, "go", "root")
+	tracer.GoRecover(ctx, "job", func(ctx context.Context) {
+		panic("boom")
+	})
+	finish()
+	if !rec.Wait(2, time.Second) {
+		t.Fatal("goroutine span not finished")
+	}
+	for _, s := range rec.Spans() {
+		if s.Operation == "job" {
+			if !s.Error() || s.Tag("panic") != "true" {
+				t.Errorf("panic not recorded: %v", s.Tags)
+			}
+			return
+		}
+	}
+	t.Fatal("no job span")
+}
+
+// TestGoRepanic tracer.Go 不吞掉 panic ，进程退出
+func TestGoRepanic(t *testing.T) {
+	if os.Getenv("TRACER_TEST_GO_PANIC") == "1" {
+		tracetest.Start(t, "go")
+		ctx, finish := startRoot(t, "go", "root")
+		defer finish()
+		tracer.Go(ctx, "job", func(ctx context.Context) {
+			panic("boom")
+		})
+		time.Sleep(time.Second)
+		return
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestGoRepanic$")
+	cmd.Env = append(os.Environ(), "TRACER_TEST_GO_PANIC=1")
+	out, err := cmd.CombinedOutput()
+	if err == nil || !strings.Contains(string(out), "panic: boom") {
+		t.Fatalf("want process to crash with the panic, got %v\n%s", err, out)
+	}
+}
+
+func TestGroupPanic(t *testing.T) {
+	rec := tracetest.Start(t, "go")
+	ctx, finish := startRoot(t, "go", "root")
+	g, ctx := tracer.NewGroup(ctx)
+	g.Go("ok", func(ctx context.Context) error { return nil })
+	g.Go("bad", func(ctx context.Context) error { panic("boom") })
+	err := g.Wait()
+	finish()
+	if err == nil || err.Error() != "panic: boom" {
+		t.Fatalf("got %v, want panic: boom without stack", err)
+	}
+	want := "go: root [error]\n  go: bad [component=goroutine error]\n  go: ok [component=goroutine]\n"
+	if got := rec.Tree(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -10,7 +10,9 @@ import (
 	"github.com/opentracing/opentracing-go/ext"
 	"github.com/opentracing/opentracing-go/log"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 // RPCUnaryClientInterceptorOption 用来设置 gRPC tracer 拦截器
@@ -25,7 +27,7 @@ func RPCStreamClientInterceptorOption(tracerName string) grpc.DialOption {
 
 func gRPCUnaryClientInterceptor(tracerName string) grpc.UnaryClientInterceptor {
 	return func(ctx context.Context, method string, req, resp interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
-		if tracer := Get(tracerName); tracer != nil {
+		if tracer := Get(tracerName); tracer != nil && !skipped(tracerName, method) {
 			var err error
 			var parentCtx opentracing.SpanContext
 			if parent := opentracing.SpanFromContext(ctx); parent != nil {
@@ -39,14 +41,22 @@ func gRPCUnaryClientInterceptor(tracerName string) grpc.UnaryClientInterceptor {
 				ext.SpanKindRPCClient,
 			)
 			defer span.Finish()
-			ctx = injectSpanContext(ctx, tracer, span)
-			span.LogFields(log.Object("gRPC request", req))
-			err = invoker(ctx, method, req, resp, cc, opts...)
+			ctx = injectSpanContext(ctx, tracerName, tracer, span)
+			ctx = injectBudget(ctx, span)
+			if !getOptions(tracerName).GRPC.NoPayload {
+				span.LogFields(log.Object("gRPC request", req))
+			}
+			if err = injectFault(ctx, tracerName, span, faultGRPC, method); err != nil {
+				err = status.Error(codes.Unavailable, err.Error())
+			} else {
+				err = invoker(ctx, method, req, resp, cc, opts...)
+			}
 			if err == nil {
-				span.LogFields(log.Object("gRPC response", resp))
+				if !getOptions(tracerName).GRPC.NoPayload {
+					span.LogFields(log.Object("gRPC response", resp))
+				}
 			} else {
-				ext.Error.Set(span, true)
-				span.LogFields(log.String("event", "error"), log.String("message", err.Error()))
+				setSpanError(ctx, tracerName, span, err)
 			}
 			return err
 		}
@@ -56,7 +66,7 @@ func gRPCUnaryClientInterceptor(tracerName string) grpc.UnaryClientInterceptor {
 
 func gRPCStreamClientInterceptor(tracerName string) grpc.StreamClientInterceptor {
 	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
-		if tracer := Get(tracerName); tracer != nil {
+		if tracer := Get(tracerName); tracer != nil && !skipped(tracerName, method) {
 			var err error
 			var parentCtx opentracing.SpanContext
 			if parent := opentracing.SpanFromContext(ctx); parent != nil {
@@ -69,22 +79,27 @@ func gRPCStreamClientInterceptor(tracerName string) grpc.StreamClientInterceptor
 				opentracing.Tag{Key: string(ext.Component), Value: "gRPC"},
 				ext.SpanKindRPCClient,
 			)
-			ctx = injectSpanContext(ctx, tracer, span)
-			w, err := streamer(ctx, desc, cc, method, opts...)
+			ctx = injectSpanContext(ctx, tracerName, tracer, span)
+			ctx = injectBudget(ctx, span)
+			var w grpc.ClientStream
+			if err = injectFault(ctx, tracerName, span, faultGRPC, method); err != nil {
+				err = status.Error(codes.Unavailable, err.Error())
+			} else {
+				w, err = streamer(ctx, desc, cc, method, opts...)
+			}
 			if err != nil {
-				span.LogFields(log.String("event", "error"), log.String("message", err.Error()))
-				ext.Error.Set(span, true)
+				setSpanError(ctx, tracerName, span, err)
 				span.Finish()
 				return w, err
 			}
-			return createClientStream(w, method, desc, span), nil
+			return createClientStream(ctx, tracerName, w, method, desc, span), nil
 		}
 		return streamer(ctx, desc, cc, method, opts...)
 	}
 }
 
-func createClientStream(w grpc.ClientStream, method string, desc *grpc.StreamDesc, span opentracing.Span) grpc.ClientStream {
-	otcs := newWrappedClientStream(w, desc, span)
+func createClientStream(ctx context.Context, tracerName string, w grpc.ClientStream, method string, desc *grpc.StreamDesc, span opentracing.Span) grpc.ClientStream {
+	otcs := newWrappedClientStream(ctx, tracerName, w, desc, span)
 
 	go func() {
 		select {
@@ -110,15 +125,19 @@ func createClientStream(w grpc.ClientStream, method string, desc *grpc.StreamDes
 
 type wrappedClientStream struct {
 	grpc.ClientStream
+	ctx        context.Context // 调用方的 ctx ，用于判断由谁取消
+	tracerName string
 	desc       *grpc.StreamDesc
 	span       opentracing.Span
 	once       sync.Once
 	finishChan chan struct{}
 }
 
-func newWrappedClientStream(w grpc.ClientStream, desc *grpc.StreamDesc, span opentracing.Span) *wrappedClientStream {
+func newWrappedClientStream(ctx context.Context, tracerName string, w grpc.ClientStream, desc *grpc.StreamDesc, span opentracing.Span) *wrappedClientStream {
 	return &wrappedClientStream{
 		ClientStream: w,
+		ctx:          ctx,
+		tracerName:   tracerName,
 		desc:         desc,
 		span:         span,
 		finishChan:   make(chan struct{}),
@@ -169,8 +188,7 @@ func (w *wrappedClientStream) finish(err error) {
 		close(w.finishChan)
 		defer w.span.Finish()
 		if err != nil {
-			w.span.LogFields(log.String("event", "error"), log.String("message", err.Error()))
-			ext.Error.Set(w.span, true)
+			setSpanError(w.ctx, w.tracerName, w.span, err)
 		}
 	})
 }
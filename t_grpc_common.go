@@ -9,26 +9,43 @@ import (
 	"google.golang.org/grpc/metadata"
 )
 
-func injectSpanContext(ctx context.Context, tracer opentracing.Tracer, span opentracing.Span) context.Context {
+func injectSpanContext(ctx context.Context, tracerName string, tracer opentracing.Tracer, span opentracing.Span) context.Context {
 	md, ok := metadata.FromOutgoingContext(ctx)
 	if !ok {
 		md = metadata.New(nil)
 	} else {
 		md = md.Copy()
 	}
-	err := tracer.Inject(span.Context(), opentracing.HTTPHeaders, metadataCarrier(md))
+	err := injectTo(tracerName, tracer, span.Context(), opentracing.HTTPHeaders, metadataCarrier(md))
 	if err != nil {
 		span.LogFields(log.String("event", "Tracer.Inject() failed"), log.Error(err))
 	}
 	return metadata.NewOutgoingContext(ctx, md)
 }
 
-func extractSpanContext(ctx context.Context, tracer opentracing.Tracer) (opentracing.SpanContext, error) {
+// injectBudget 在客户端 span 上记录剩余时间，并把预算写入 metadata
+func injectBudget(ctx context.Context, span opentracing.Span) context.Context {
+	if budget, ok := clientBudget(ctx, span); ok {
+		return metadata.AppendToOutgoingContext(ctx, strings.ToLower(CallerBudgetHeader), formatMillis(budget))
+	}
+	return ctx
+}
+
+// incomingValue 获取请求 metadata 中 key 的第一个值
+func incomingValue(ctx context.Context, key string) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if vals := md.Get(key); len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
+}
+
+func extractSpanContext(ctx context.Context, tracerName string, tracer opentracing.Tracer) (opentracing.SpanContext, error) {
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
 		md = metadata.New(nil)
 	}
-	return tracer.Extract(opentracing.HTTPHeaders, metadataCarrier(md))
+	return extractFrom(tracerName, tracer, opentracing.HTTPHeaders, metadataCarrier(md))
 }
 
 // metadataCarrier
@@ -8,6 +8,7 @@ import (
 	"github.com/opentracing/opentracing-go/log"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/grpclog"
+	"google.golang.org/grpc/metadata"
 )
 
 // RPCUnaryServerInterceptorOption 用来设置 gRPC tracer 拦截器
@@ -23,30 +24,41 @@ func RPCStreamServerInterceptorOption(tracerName string) grpc.ServerOption {
 // gRPCUnaryServerInterceptor gRPC 服务器端，一元拦截器
 func gRPCUnaryServerInterceptor(tracerName string) grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
-		if tracer := Get(tracerName); tracer != nil {
-			spanContext, err := extractSpanContext(ctx, tracer)
+		if tracer := Get(tracerName); tracer != nil && !skipped(tracerName, info.FullMethod) {
+			spanContext, err := extractSpanContext(ctx, tracerName, tracer)
 			if err != nil && err != opentracing.ErrSpanContextNotFound {
 				// 如果 tracer extract 失败，那么跳过追踪
 				grpclog.Errorf("SpanContext Extract Error! %s", err.Error())
 				return handler(ctx, req)
 			}
 
-			span := tracer.StartSpan(
-				info.FullMethod,
-				ext.RPCServerOption(spanContext),
-				opentracing.Tag{Key: string(ext.Component), Value: "gRPC"},
+			tags := spanTags{
+				{Key: string(ext.Component), Value: "gRPC"},
 				ext.SpanKindRPCServer,
-			)
+			}
+			span := tracer.StartSpan(info.FullMethod, ext.RPCServerOption(spanContext), tags)
 			defer span.Finish()
+			defer watchSpan(tracerName, span, tags)()
 
+			ctx = serverBudget(ctx, tracerName, span, incomingValue(ctx, CallerBudgetHeader))
 			ctx = opentracing.ContextWithSpan(ctx, span)
-			span.LogFields(log.Object("gRPC request", req))
-			resp, err = handler(ctx, req)
+			if h := getOptions(tracerName).GRPC.TraceIDHeader; h != "" {
+				md := metadata.Pairs(h, traceIDOf(tracer, span.Context()))
+				grpc.SetHeader(ctx, md)
+				grpc.SetTrailer(ctx, md)
+			}
+			if !getOptions(tracerName).GRPC.NoPayload {
+				span.LogFields(log.Object("gRPC request", req))
+			}
+			doWithProfileLabels(ctx, tracerName, span, info.FullMethod, func(ctx context.Context) {
+				resp, err = handler(ctx, req)
+			})
 			if err == nil {
-				span.LogFields(log.Object("gRPC response", resp))
+				if !getOptions(tracerName).GRPC.NoPayload {
+					span.LogFields(log.Object("gRPC response", resp))
+				}
 			} else {
-				ext.Error.Set(span, true)
-				span.LogFields(log.String("event", "error"), log.String("message", err.Error()))
+				setSpanError(ctx, tracerName, span, err)
 			}
 			return resp, err
 		}
@@ -57,26 +69,33 @@ func gRPCUnaryServerInterceptor(tracerName string) grpc.UnaryServerInterceptor {
 // gRPCStreamServerInterceptor
 func gRPCStreamServerInterceptor(tracerName string) grpc.StreamServerInterceptor {
 	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
-		if tracer := Get(tracerName); tracer != nil {
-			spanContext, err := extractSpanContext(ss.Context(), tracer)
+		if tracer := Get(tracerName); tracer != nil && !skipped(tracerName, info.FullMethod) {
+			spanContext, err := extractSpanContext(ss.Context(), tracerName, tracer)
 			if err != nil && err != opentracing.ErrSpanContextNotFound {
 				// 如果 tracer extract 失败，那么跳过追踪
 				grpclog.Errorf("SpanContext Extract Error! %s", err.Error())
 				return handler(srv, ss)
 			}
 
-			span := tracer.StartSpan(
-				info.FullMethod,
-				ext.RPCServerOption(spanContext),
-				opentracing.Tag{Key: string(ext.Component), Value: "gRPC Server"},
+			tags := spanTags{
+				{Key: string(ext.Component), Value: "gRPC Server"},
 				ext.SpanKindRPCServer,
-			)
+			}
+			span := tracer.StartSpan(info.FullMethod, ext.RPCServerOption(spanContext), tags)
 			defer span.Finish()
+			defer watchSpan(tracerName, span, tags)()
 
-			err = handler(srv, newWrappedServerStream(opentracing.ContextWithSpan(ss.Context(), span), ss))
+			if h := getOptions(tracerName).GRPC.TraceIDHeader; h != "" {
+				md := metadata.Pairs(h, traceIDOf(tracer, span.Context()))
+				ss.SetHeader(md)
+				ss.SetTrailer(md)
+			}
+			ctx := serverBudget(ss.Context(), tracerName, span, incomingValue(ss.Context(), CallerBudgetHeader))
+			doWithProfileLabels(opentracing.ContextWithSpan(ctx, span), tracerName, span, info.FullMethod, func(ctx context.Context) {
+				err = handler(srv, newWrappedServerStream(ctx, ss))
+			})
 			if err != nil {
-				ext.Error.Set(span, true)
-				span.LogFields(log.String("event", "error"), log.String("message", err.Error()))
+				setSpanError(ctx, tracerName, span, err)
 			}
 			return err
 		}
@@ -0,0 +1,130 @@
+package tracer_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"runtime/pprof"
+	"testing"
+	"time"
+
+	"github.com/fananchong/tracer"
+	pb "github.com/fananchong/tracer/examples/proto"
+	"github.com/fananchong/tracer/spans"
+	"github.com/fananchong/tracer/tracetest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// labelServer 记录 handler 中 ctx 的 pprof 标签
+type labelServer struct {
+	pb.UnimplementedEchoServer
+	labels chan map[string]string
+}
+
+func profileLabels(ctx context.Context) map[string]string {
+	labels := map[string]string{}
+	for _, key := range []string{tracer.ProfileLabelService, tracer.ProfileLabelOperation, tracer.ProfileLabelTraceID, tracer.ProfileLabelSpanID} {
+		if v, ok := pprof.Label(ctx, key); ok {
+			labels[key] = v
+		}
+	}
+	return labels
+}
+
+func (s *labelServer) UnaryEcho(ctx context.Context, req *pb.EchoRequest) (*pb.EchoResponse, error) {
+	s.labels <- profileLabels(ctx)
+	return &pb.EchoResponse{Message: req.Message}, nil
+}
+
+func (s *labelServer) ServerStreamingEcho(req *pb.EchoRequest, stream pb.Echo_ServerStreamingEchoServer) error {
+	s.labels <- profileLabels(stream.Context())
+	return stream.Send(&pb.EchoResponse{Message: req.Message})
+}
+
+// dialLabelServer 启动带追踪拦截器的 gRPC 服务器，返回没有追踪的客户端
+func dialLabelServer(t *testing.T, tracerName string, srv pb.EchoServer) pb.EchoClient {
+	t.Helper()
+	l := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(
+		tracer.RPCUnaryServerInterceptorOption(tracerName),
+		tracer.RPCStreamServerInterceptorOption(tracerName),
+	)
+	pb.RegisterEchoServer(s, srv)
+	go s.Serve(l)
+	t.Cleanup(s.Stop)
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return l.Dial() }),
+		grpc.WithInsecure(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewEchoClient(conn)
+}
+
+// checkProfileLabels 标签与服务器端 span 一致
+func checkProfileLabels(t *testing.T, labels map[string]string, service, operation string, span *spans.Span) {
+	t.Helper()
+	want := map[string]string{
+		tracer.ProfileLabelService:   service,
+		tracer.ProfileLabelOperation: operation,
+		tracer.ProfileLabelTraceID:   span.TraceID,
+		tracer.ProfileLabelSpanID:    span.SpanID,
+	}
+	for k, v := range want {
+		if labels[k] != v {
+			t.Errorf("got label %s=%q, want %q", k, labels[k], v)
+		}
+	}
+}
+
+// TestGRPCServerProfileLabels 打开 Profile.Labels 时， unary 、 stream handler 在 pprof.Do 中执行
+func TestGRPCServerProfileLabels(t *testing.T) {
+	rec := tracetest.Start(t, "grpc")
+	tracer.SetOptions("grpc", tracer.Options{Profile: tracer.ProfileOptions{Labels: true}})
+	srv := &labelServer{labels: make(chan map[string]string, 1)}
+	client := dialLabelServer(t, "grpc", srv)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := client.UnaryEcho(ctx, &pb.EchoRequest{Message: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	labels := <-srv.labels
+	if !rec.Wait(1, time.Second) {
+		t.Fatalf("got %d spans, want 1", len(rec.Spans()))
+	}
+	checkProfileLabels(t, labels, "grpc", "/proto.Echo/UnaryEcho", rec.Spans()[0])
+
+	stream, err := client.ServerStreamingEcho(ctx, &pb.EchoRequest{Message: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if _, err := stream.Recv(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	labels = <-srv.labels
+	if !rec.Wait(2, time.Second) {
+		t.Fatalf("got %d spans, want 2", len(rec.Spans()))
+	}
+	checkProfileLabels(t, labels, "grpc", "/proto.Echo/ServerStreamingEcho", rec.Spans()[1])
+}
+
+// TestGRPCServerNoProfileLabels 没有打开 Profile.Labels 时，不设置标签
+func TestGRPCServerNoProfileLabels(t *testing.T) {
+	tracetest.Start(t, "grpc")
+	srv := &labelServer{labels: make(chan map[string]string, 1)}
+	client := dialLabelServer(t, "grpc", srv)
+	if _, err := client.UnaryEcho(context.Background(), &pb.EchoRequest{Message: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if labels := <-srv.labels; len(labels) != 0 {
+		t.Errorf("got labels %v", labels)
+	}
+}
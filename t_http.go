@@ -0,0 +1,127 @@
+package tracer
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"google.golang.org/grpc/grpclog"
+)
+
+// HTTPMiddleware net/http 的中间件
+func HTTPMiddleware(tracerName string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if tracer := Get(tracerName); tracer != nil && !skipped(tracerName, r.URL.Path) {
+			carrier := opentracing.HTTPHeadersCarrier(r.Header)
+			spanContext, err := extractFrom(tracerName, tracer, opentracing.HTTPHeaders, carrier)
+			if err != nil && err != opentracing.ErrSpanContextNotFound {
+				// 如果 tracer extract 失败，那么跳过追踪
+				grpclog.Errorf("SpanContext Extract Error! %s", err.Error())
+				next.ServeHTTP(w, r)
+				return
+			}
+			operationName := "HTTP " + r.Method + " " + r.URL.Path
+			tags := httpServerTags(r)
+			span := tracer.StartSpan(operationName, ext.RPCServerOption(spanContext), tags)
+			defer span.Finish()
+			defer watchSpan(tracerName, span, tags)()
+
+			if h := getOptions(tracerName).HTTP.TraceIDHeader; h != "" {
+				w.Header().Set(h, traceIDOf(tracer, span.Context()))
+			}
+
+			ctx, cancel := httpServerDeadline(r, tracerName, span)
+			defer cancel()
+
+			sw := &statusResponseWriter{ResponseWriter: w, status: http.StatusOK}
+			if err := injectFault(ctx, tracerName, span, faultHTTP, r.URL.Path); err != nil {
+				http.Error(sw, err.Error(), http.StatusServiceUnavailable)
+			} else {
+				doWithProfileLabels(opentracing.ContextWithSpan(ctx, span), tracerName, span, operationName, func(ctx context.Context) {
+					next.ServeHTTP(sw, r.WithContext(ctx))
+				})
+			}
+			ext.HTTPStatusCode.Set(span, uint16(sw.status))
+			if sw.status >= http.StatusInternalServerError {
+				ext.Error.Set(span, true)
+			}
+			if err := ctx.Err(); err != nil {
+				setSpanError(ctx, tracerName, span, err)
+			}
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// httpServerTags HTTP 服务器端 span 开始时的 tag
+func httpServerTags(r *http.Request) spanTags {
+	return spanTags{
+		{Key: string(ext.Component), Value: "HTTP"},
+		ext.SpanKindRPCServer,
+		{Key: string(ext.HTTPMethod), Value: r.Method},
+		{Key: string(ext.HTTPUrl), Value: r.URL.String()},
+	}
+}
+
+// httpServerDeadline DeadlineOptions.AcceptHeader 打开时，按请求头 X-Deadline-Ms 设置 ctx 的 deadline （不超过 MaxHeader ），
+// 并记录剩余时间、调用方预算
+func httpServerDeadline(r *http.Request, tracerName string, span opentracing.Span) (context.Context, context.CancelFunc) {
+	ctx, cancel := r.Context(), context.CancelFunc(func() {})
+	if o := getOptions(tracerName).Deadline; o.AcceptHeader {
+		// 超过 time.Duration 范围的值按最大值处理，避免溢出
+		if ms, err := strconv.ParseInt(r.Header.Get(DeadlineHeader), 10, 64); err == nil && ms > 0 {
+			timeout := time.Duration(math.MaxInt64)
+			if ms < int64(timeout/time.Millisecond) {
+				timeout = time.Duration(ms) * time.Millisecond
+			}
+			if o.MaxHeader > 0 && timeout > o.MaxHeader {
+				timeout = o.MaxHeader
+			}
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+		}
+	}
+	return serverBudget(ctx, tracerName, span, r.Header.Get(CallerBudgetHeader)), cancel
+}
+
+// statusResponseWriter 记录应答的状态码
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusResponseWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Flush 实现 http.Flusher ，流式应答（比如 SSE ）需要
+func (w *statusResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack 实现 http.Hijacker ， websocket 需要
+func (w *statusResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("tracer: ResponseWriter does not implement http.Hijacker")
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+func (w *statusResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,130 @@
+package tracer
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+// HTTPTransport 带追踪的 http.RoundTripper
+// 以请求 ctx 中的 span 为父 span ，创建客户端 span ，并把 span context 写入请求头；
+// ctx 有 deadline 时，发送剩余时间（ X-Deadline-Ms ）与调用方预算（ X-Caller-Budget-Ms ）
+// span 在应答 body 读到 EOF 、读取出错或 Close 时结束（包含读取 body 的时间），调用方必须关闭 body
+// base 为 nil 时使用 http.DefaultTransport
+//
+//	client := &http.Client{Transport: tracer.HTTPTransport(tracerName, nil)}
+//	req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
+//	resp, err := client.Do(req)
+func HTTPTransport(tracerName string, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &httpTransport{base: base, tracerName: tracerName}
+}
+
+type httpTransport struct {
+	base       http.RoundTripper
+	tracerName string
+}
+
+func (t *httpTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	tracer := Get(t.tracerName)
+	if tracer == nil || skipped(t.tracerName, r.URL.Path) {
+		return t.base.RoundTrip(r)
+	}
+	var parentCtx opentracing.SpanContext
+	if parent := opentracing.SpanFromContext(r.Context()); parent != nil {
+		parentCtx = parent.Context()
+	}
+	span := tracer.StartSpan(
+		"HTTP "+r.Method+" "+r.URL.Path,
+		opentracing.ChildOf(parentCtx),
+		opentracing.Tag{Key: string(ext.Component), Value: "HTTP"},
+		ext.SpanKindRPCClient,
+	)
+	ext.HTTPMethod.Set(span, r.Method)
+	ext.HTTPUrl.Set(span, r.URL.String())
+
+	// RoundTripper 不能修改原请求
+	r = r.Clone(r.Context())
+	if err := injectTo(t.tracerName, tracer, span.Context(), opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(r.Header)); err != nil {
+		span.LogFields(log.String("event", "Tracer.Inject() failed"), log.Error(err))
+	}
+	if left, ok := remaining(r.Context()); ok {
+		r.Header.Set(DeadlineHeader, formatMillis(left))
+	}
+	if budget, ok := clientBudget(r.Context(), span); ok {
+		r.Header.Set(CallerBudgetHeader, formatMillis(budget))
+	}
+
+	resp, err := t.base.RoundTrip(r)
+	if err != nil {
+		setSpanError(r.Context(), t.tracerName, span, err)
+		span.Finish()
+		return resp, err
+	}
+	ext.HTTPStatusCode.Set(span, uint16(resp.StatusCode))
+	if resp.StatusCode >= http.StatusInternalServerError {
+		ext.Error.Set(span, true)
+	}
+	if resp.Body == nil || resp.Body == http.NoBody {
+		span.Finish()
+		return resp, nil
+	}
+	body := &spanBody{ReadCloser: resp.Body, tracerName: t.tracerName, ctx: r.Context(), span: span}
+	if rw, ok := resp.Body.(io.ReadWriteCloser); ok {
+		// 101 Switching Protocols 的 body 可写，保留 io.Writer
+		resp.Body = &spanRWBody{spanBody: body, w: rw}
+	} else {
+		resp.Body = body
+	}
+	return resp, nil
+}
+
+// spanBody 应答 body 读到 EOF 、读取出错或 Close 时结束 span
+type spanBody struct {
+	io.ReadCloser
+	tracerName string
+	ctx        context.Context
+	span       opentracing.Span
+	once       sync.Once
+}
+
+func (b *spanBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.finish(nil)
+	} else if err != nil {
+		b.finish(err)
+	}
+	return n, err
+}
+
+func (b *spanBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish(nil)
+	return err
+}
+
+func (b *spanBody) finish(err error) {
+	b.once.Do(func() {
+		if err != nil {
+			setSpanError(b.ctx, b.tracerName, b.span, err)
+		}
+		b.span.Finish()
+	})
+}
+
+type spanRWBody struct {
+	*spanBody
+	w io.Writer
+}
+
+func (b *spanRWBody) Write(p []byte) (int, error) {
+	return b.w.Write(p)
+}
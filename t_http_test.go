@@ -0,0 +1,194 @@
+package tracer_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fananchong/tracer"
+	"github.com/fananchong/tracer/tracetest"
+)
+
+func TestHTTPMiddlewareFlush(t *testing.T) {
+	rec := tracetest.Start(t, "http")
+	h := tracer.HTTPMiddleware("http", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, ok := w.(http.Flusher)
+		if !ok {
+			t.Error("ResponseWriter does not implement http.Flusher")
+			return
+		}
+		w.Write([]byte("data: 1\n\n"))
+		f.Flush()
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/events", nil))
+	if !w.Flushed {
+		t.Error("Flush not forwarded")
+	}
+	if got := rec.Tree(); got != "http: HTTP GET /events [kind=server component=HTTP]\n" {
+		t.Errorf("got\n%s", got)
+	}
+}
+
+// TestHTTPMiddlewareHijack websocket 等协议升级需要 http.Hijacker
+func TestHTTPMiddlewareHijack(t *testing.T) {
+	rec := tracetest.Start(t, "http")
+	srv := httptest.NewServer(tracer.HTTPMiddleware("http", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hj, ok := w.(http.Hijacker)
+		if !ok {
+			t.Error("ResponseWriter does not implement http.Hijacker")
+			return
+		}
+		conn, buf, err := hj.Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: test\r\nConnection: Upgrade\r\n\r\n")
+		buf.Flush()
+	})))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/ws")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("got status %d", resp.StatusCode)
+	}
+	rec.Wait(1, time.Second)
+	if got := rec.Spans(); len(got) != 1 || got[0].Tag("http.status_code") != "101" {
+		t.Errorf("got %+v", got)
+	}
+}
+
+// TestHTTPMiddlewareTraceIDHeader 应答头中的 trace id 与 span 一致
+func TestHTTPMiddlewareTraceIDHeader(t *testing.T) {
+	rec := tracetest.Start(t, "http")
+	tracer.SetOptions("http", tracer.Options{HTTP: tracer.HTTPOptions{TraceIDHeader: "X-Trace-Id"}})
+	h := tracer.HTTPMiddleware("http", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	got := rec.Spans()
+	if len(got) != 1 || w.Header().Get("X-Trace-Id") != got[0].TraceID || len(got[0].TraceID) != 32 {
+		t.Errorf("got header %q, spans %+v", w.Header().Get("X-Trace-Id"), got)
+	}
+}
+
+// TestHTTPMiddlewareDeadlineHeader X-Deadline-Ms 默认忽略；打开后不大于 0 的值忽略，超过上限时按上限
+func TestHTTPMiddlewareDeadlineHeader(t *testing.T) {
+	tests := []struct {
+		opts   tracer.DeadlineOptions
+		header string
+		want   time.Duration // 0 表示没有 deadline
+	}{
+		{tracer.DeadlineOptions{}, "1000", 0},
+		{tracer.DeadlineOptions{AcceptHeader: true}, "1000", time.Second},
+		{tracer.DeadlineOptions{AcceptHeader: true}, "0", 0},
+		{tracer.DeadlineOptions{AcceptHeader: true}, "-5", 0},
+		{tracer.DeadlineOptions{AcceptHeader: true}, "abc", 0},
+		{tracer.DeadlineOptions{AcceptHeader: true, MaxHeader: 2 * time.Second}, "600000", 2 * time.Second},
+		{tracer.DeadlineOptions{AcceptHeader: true, MaxHeader: 2 * time.Second}, "9223372036854775807", 2 * time.Second},
+	}
+	tracetest.Start(t, "http")
+	for _, tt := range tests {
+		tracer.SetOptions("http", tracer.Options{Deadline: tt.opts})
+		var left time.Duration
+		h := tracer.HTTPMiddleware("http", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if deadline, ok := r.Context().Deadline(); ok {
+				left = time.Until(deadline)
+			}
+		}))
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(tracer.DeadlineHeader, tt.header)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		if tt.want == 0 && left != 0 || tt.want != 0 && (left <= tt.want-time.Second/2 || left > tt.want) {
+			t.Errorf("%+v %s: got deadline in %s, want %s", tt.opts, tt.header, left, tt.want)
+		}
+	}
+}
+
+// TestHTTPTransportBody 客户端 span 在 body Close 时结束；预算按调用时 ctx 的 deadline 计算
+func TestHTTPTransportBody(t *testing.T) {
+	rec := tracetest.Start(t, "http")
+	var budget string
+	client := &http.Client{Transport: tracer.HTTPTransport("http", roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		budget = r.Header.Get(tracer.CallerBudgetHeader)
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("body")), Request: r}, nil
+	}))}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", "http://example.com/", nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ms, _ := strconv.Atoi(budget); ms <= 0 || ms > 100 {
+		t.Errorf("got budget %q", budget)
+	}
+	if n := len(rec.Spans()); n != 0 {
+		t.Fatalf("span finished before the body is closed")
+	}
+	if b, err := ioutil.ReadAll(resp.Body); err != nil || string(b) != "body" {
+		t.Fatalf("got %q %v", b, err)
+	}
+	resp.Body.Close()
+	if n := len(rec.Spans()); n != 1 {
+		t.Fatalf("got %d spans, want 1", n)
+	}
+}
+
+// TestHTTPTransportBudgetShortened 服务器端处理时缩短了 deadline ，发给下游的预算不超过新的剩余时间
+func TestHTTPTransportBudgetShortened(t *testing.T) {
+	tracetest.Start(t, "http")
+	tracer.SetOptions("http", tracer.Options{Deadline: tracer.DeadlineOptions{AcceptHeader: true}})
+	var budget string
+	client := &http.Client{Transport: tracer.HTTPTransport("http", roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		budget = r.Header.Get(tracer.CallerBudgetHeader)
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: r}, nil
+	}))}
+	h := tracer.HTTPMiddleware("http", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 100*time.Millisecond)
+		defer cancel()
+		req, _ := http.NewRequestWithContext(ctx, "GET", "http://example.com/", nil)
+		if resp, err := client.Do(req); err == nil {
+			resp.Body.Close()
+		}
+	}))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(tracer.DeadlineHeader, "5000")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if ms, _ := strconv.Atoi(budget); ms <= 0 || ms > 100 {
+		t.Errorf("got budget %q, want <= 100", budget)
+	}
+}
+
+// TestHTTPMiddlewareProfileLabels 打开 Profile.Labels 时， handler 在 pprof.Do 中执行
+func TestHTTPMiddlewareProfileLabels(t *testing.T) {
+	rec := tracetest.Start(t, "http")
+	tracer.SetOptions("http", tracer.Options{Profile: tracer.ProfileOptions{Labels: true}})
+	labels := map[string]string{}
+	h := tracer.HTTPMiddleware("http", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pprof.ForLabels(r.Context(), func(key, value string) bool {
+			labels[key] = value
+			return true
+		})
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/test1", nil))
+	got := rec.Spans()
+	if len(got) != 1 {
+		t.Fatalf("got %d spans, want 1", len(got))
+	}
+	if labels[tracer.ProfileLabelService] != "http" || labels[tracer.ProfileLabelOperation] != "HTTP GET /test1" ||
+		labels[tracer.ProfileLabelTraceID] != got[0].TraceID || labels[tracer.ProfileLabelSpanID] == "" {
+		t.Errorf("got labels %v", labels)
+	}
+}
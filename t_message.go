@@ -0,0 +1,214 @@
+package tracer
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+// 消息队列的追踪
+// 适用于 NSQ/Kafka 等消息队列，以及进程内的消息总线
+// 生产者创建 producer span ，并把 span context 写入消息头；
+// 消费者创建 consumer span ，以 FollowsFrom 关联生产者（批量消费时关联多个生产者）
+//
+// 生产者：
+//   span, ctx := tracer.StartProducerSpan(ctx, tracerName, topic)
+//   defer span.Finish()
+//   headers := map[string]string{}
+//   tracer.InjectMessage(ctx, tracerName, headers)
+//
+// 消费者：
+//   span, ctx := tracer.StartConsumerSpan(tracerName, topic, msg.Headers)
+//   defer span.Finish()
+
+// StartProducerSpan 发送消息前调用，创建 producer span
+// tracer 未打开时，返回 noop span
+func StartProducerSpan(ctx context.Context, tracerName, destination string) (opentracing.Span, context.Context) {
+	tracer := Get(tracerName)
+	if tracer == nil {
+		return opentracing.NoopTracer{}.StartSpan(destination), ctx
+	}
+	var parentCtx opentracing.SpanContext
+	if parent := opentracing.SpanFromContext(ctx); parent != nil {
+		parentCtx = parent.Context()
+	}
+	span := tracer.StartSpan(
+		"Send "+destination,
+		opentracing.ChildOf(parentCtx),
+		opentracing.Tag{Key: string(ext.Component), Value: "message"},
+		ext.SpanKindProducer,
+	)
+	ext.MessageBusDestination.Set(span, destination)
+	return span, opentracing.ContextWithSpan(ctx, span)
+}
+
+// InjectMessage 把 ctx 中的 span context 写入消息头，格式由 tracerName 的 Options.Propagation 决定
+// ctx 中没有 span 时，不写入
+func InjectMessage(ctx context.Context, tracerName string, headers map[string]string) error {
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil {
+		return nil
+	}
+	err := injectTo(tracerName, span.Tracer(), span.Context(), opentracing.TextMap, opentracing.TextMapCarrier(headers))
+	if err != nil {
+		span.LogFields(log.String("event", "Tracer.Inject() failed"), log.Error(err))
+	}
+	return err
+}
+
+// StartConsumerSpan 收到消息后调用，创建 consumer span
+// 每个消息头对应一个 FollowsFrom 引用，批量消费时传入多个消息头
+// 按 tracerName 的 Options.Propagation 依次尝试各格式
+// tracer 未打开时，返回 noop span
+func StartConsumerSpan(tracerName, destination string, headers ...map[string]string) (opentracing.Span, context.Context) {
+	ctx := context.Background()
+	tracer := Get(tracerName)
+	if tracer == nil {
+		return opentracing.NoopTracer{}.StartSpan(destination), ctx
+	}
+	opts := []opentracing.StartSpanOption{
+		opentracing.Tag{Key: string(ext.Component), Value: "message"},
+		ext.SpanKindConsumer,
+	}
+	var failed []error
+	for _, h := range headers {
+		spanContext, err := extractFrom(tracerName, tracer, opentracing.TextMap, opentracing.TextMapCarrier(h))
+		if err == nil {
+			opts = append(opts, opentracing.FollowsFrom(spanContext))
+		} else if err != opentracing.ErrSpanContextNotFound {
+			failed = append(failed, err)
+		}
+	}
+	span := tracer.StartSpan("Receive "+destination, opts...)
+	ext.MessageBusDestination.Set(span, destination)
+	if len(headers) > 1 {
+		span.SetTag("message.batch_size", len(headers))
+	}
+	for _, err := range failed {
+		span.LogFields(log.String("event", "Tracer.Extract() failed"), log.Error(err))
+	}
+	return span, opentracing.ContextWithSpan(ctx, span)
+}
+
+// Message 消息
+type Message struct {
+	Headers map[string]string
+	Body    []byte
+}
+
+// ErrQueueClosed 消息队列已关闭
+var ErrQueueClosed = errors.New("tracer: queue closed")
+
+// ChanQueue 基于 channel 的消息队列
+// 可用作进程内的消息总线，也是消息队列追踪的参考实现，方便测试
+type ChanQueue struct {
+	topic   string
+	ch      chan Message
+	mu      sync.Mutex
+	closed  bool
+	done    chan struct{}  // Close 时关闭，唤醒阻塞中的 Publish
+	sending sync.WaitGroup // 正在发送的 Publish ，全部返回后才能关闭 ch
+}
+
+// NewChanQueue ChanQueue 构造函数
+func NewChanQueue(topic string, size int) *ChanQueue {
+	return &ChanQueue{
+		topic: topic,
+		ch:    make(chan Message, size),
+		done:  make(chan struct{}),
+	}
+}
+
+// Publish 发送消息
+// 队列满时阻塞，直到消息发出、 ctx 结束或者队列关闭
+func (q *ChanQueue) Publish(ctx context.Context, tracerName string, body []byte) error {
+	span, ctx := StartProducerSpan(ctx, tracerName, q.topic)
+	defer span.Finish()
+	msg := Message{Headers: map[string]string{}, Body: body}
+	InjectMessage(ctx, tracerName, msg.Headers)
+
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return publishError(span, ErrQueueClosed)
+	}
+	q.sending.Add(1)
+	q.mu.Unlock()
+	defer q.sending.Done()
+
+	select {
+	case q.ch <- msg:
+		return nil
+	case <-q.done:
+		return publishError(span, ErrQueueClosed)
+	case <-ctx.Done():
+		return publishError(span, ctx.Err())
+	}
+}
+
+func publishError(span opentracing.Span, err error) error {
+	ext.Error.Set(span, true)
+	span.LogFields(log.String("event", "error"), log.String("message", err.Error()))
+	return err
+}
+
+// Consume 逐个消费消息，直到队列关闭
+func (q *ChanQueue) Consume(tracerName string, handler func(ctx context.Context, msg Message) error) {
+	for msg := range q.ch {
+		span, ctx := StartConsumerSpan(tracerName, q.topic, msg.Headers)
+		if err := handler(ctx, msg); err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(log.String("event", "error"), log.String("message", err.Error()))
+		}
+		span.Finish()
+	}
+}
+
+// ConsumeBatch 批量消费消息，直到队列关闭
+// 每批至少 1 个，最多 max 个消息， consumer span 关联批内所有生产者
+func (q *ChanQueue) ConsumeBatch(tracerName string, max int, handler func(ctx context.Context, msgs []Message) error) {
+	for msg := range q.ch {
+		batch := []Message{msg}
+	fill:
+		for len(batch) < max {
+			select {
+			case m, ok := <-q.ch:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, m)
+			default:
+				break fill
+			}
+		}
+		headers := make([]map[string]string, len(batch))
+		for i := range batch {
+			headers[i] = batch[i].Headers
+		}
+		span, ctx := StartConsumerSpan(tracerName, q.topic, headers...)
+		if err := handler(ctx, batch); err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(log.String("event", "error"), log.String("message", err.Error()))
+		}
+		span.Finish()
+	}
+}
+
+// Close 关闭队列，消费者处理完剩余消息后返回
+// 阻塞中的 Publish 返回 ErrQueueClosed
+func (q *ChanQueue) Close() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	close(q.done)
+	q.mu.Unlock()
+	q.sending.Wait()
+	close(q.ch)
+}
@@ -0,0 +1,93 @@
+package tracer_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fananchong/tracer"
+	"github.com/fananchong/tracer/tracetest"
+)
+
+func TestChanQueueTrace(t *testing.T) {
+	rec := tracetest.Start(t, "mq")
+	q := tracer.NewChanQueue("topic", 1)
+	ctx, finish := startRoot(t, "mq", "root")
+	if err := q.Publish(ctx, "mq", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	finish()
+	q.Close()
+	q.Consume("mq", func(ctx context.Context, msg tracer.Message) error { return nil })
+	want := "mq: root\n  mq: Send topic [kind=producer component=message]\n    mq: Receive topic [kind=consumer component=message follows_from]\n"
+	if got := rec.Tree(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+// TestChanQueueCloseFull 队列满时， Close 不会死锁，阻塞中的 Publish 返回 ErrQueueClosed
+func TestChanQueueCloseFull(t *testing.T) {
+	tracetest.Start(t, "mq")
+	q := tracer.NewChanQueue("topic", 1)
+	if err := q.Publish(context.Background(), "mq", nil); err != nil {
+		t.Fatal(err)
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- q.Publish(context.Background(), "mq", nil) }()
+	time.Sleep(20 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		q.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close deadlocked on a full queue")
+	}
+	if err := <-errc; err != tracer.ErrQueueClosed {
+		t.Fatalf("got %v, want ErrQueueClosed", err)
+	}
+	if err := q.Publish(context.Background(), "mq", nil); err != tracer.ErrQueueClosed {
+		t.Fatalf("got %v, want ErrQueueClosed", err)
+	}
+	q.Close()
+}
+
+// TestMessageW3C 消息头按 Options.Propagation 的格式写入、提取
+func TestMessageW3C(t *testing.T) {
+	rec := tracetest.Start(t, "mq")
+	tracer.SetOptions("mq", tracer.Options{Propagation: []tracer.PropagationFormat{tracer.PropagationW3C}})
+
+	span, ctx := tracer.StartProducerSpan(context.Background(), "mq", "topic")
+	headers := map[string]string{}
+	if err := tracer.InjectMessage(ctx, "mq", headers); err != nil {
+		t.Fatal(err)
+	}
+	span.Finish()
+	if headers["traceparent"] == "" || headers["uber-trace-id"] != "" {
+		t.Fatalf("got headers %v, want traceparent only", headers)
+	}
+
+	// 外部生产者只写 traceparent
+	const traceID, spanID = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+	external := map[string]string{"traceparent": "00-" + traceID + "-" + spanID + "-01"}
+	consumer, _ := tracer.StartConsumerSpan("mq", "topic", headers, external)
+	consumer.Finish()
+
+	got := rec.Spans()
+	if len(got) != 2 {
+		t.Fatalf("got %d spans, want 2", len(got))
+	}
+	producer, receive := got[0], got[1]
+	if len(receive.References) != 2 {
+		t.Fatalf("got references %+v, want 2", receive.References)
+	}
+	if ref := receive.References[0]; ref.TraceID != producer.TraceID || ref.SpanID != producer.SpanID {
+		t.Errorf("got producer ref %+v, want %s/%s", ref, producer.TraceID, producer.SpanID)
+	}
+	if ref := receive.References[1]; ref.TraceID != traceID || ref.SpanID != spanID {
+		t.Errorf("got external ref %+v, want %s/%s", ref, traceID, spanID)
+	}
+}
@@ -3,13 +3,12 @@ package tracer
 import (
 	"context"
 	"database/sql"
-	"fmt"
 	"strings"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql" //
 	"github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/ext"
-	"github.com/opentracing/opentracing-go/log"
 )
 
 // MySQL 封装例子
@@ -26,14 +25,24 @@ func MySQLPingWrap(ctx context.Context, tracerName string) {
 		}
 
 		spanName := strings.ToUpper("Ping")
-		span := tracer.StartSpan(spanName, opentracing.ChildOf(parentCtx))
-		ext.DBType.Set(span, "MySQL")
-		ext.DBStatement.Set(span, fmt.Sprintf("%v", "ping"))
+		o := &getOptions(tracerName).SQL
+		tags := spanTags{
+			{Key: string(ext.DBType), Value: "MySQL"},
+			{Key: string(ext.DBStatement), Value: sqlStatement(o, "ping")},
+		}
+		span := tracer.StartSpan(spanName, opentracing.ChildOf(parentCtx), tags)
 		defer span.Finish()
-		if err := ping(); err != nil {
-			ext.Error.Set(span, true)
-			span.LogFields(log.String("event", "error"), log.String("message", err.Error()))
+		start := time.Now()
+		err := injectFault(ctx, tracerName, span, faultMySQL, "PING")
+		if err == nil {
+			err = ping()
+		}
+		if err != nil {
+			setSpanError(ctx, tracerName, span, err)
 		}
+		markSlow(span, tags, &o.Slow, time.Since(start), func() string {
+			return redactStatement(o.Redact, "ping")
+		})
 
 	} else {
 		ping()
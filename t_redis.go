@@ -2,13 +2,16 @@ package tracer
 
 import (
 	"context"
-	"fmt"
+	"reflect"
 	"strings"
+	"time"
+	"unsafe"
 
 	"github.com/go-redis/redis"
 	"github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/ext"
 	"github.com/opentracing/opentracing-go/log"
+	"google.golang.org/grpc/grpclog"
 )
 
 // RedisClient Redis 客户端
@@ -40,18 +43,33 @@ func (rclient *RedisClient) process() func(oldProcess func(cmd redis.Cmder) erro
 				}
 
 				spanName := strings.ToUpper(cmd.Name())
-				span := tracer.StartSpan(spanName, opentracing.ChildOf(parentCtx))
-				ext.DBType.Set(span, "redis")
-				ext.DBStatement.Set(span, fmt.Sprintf("%v", cmd.Args()))
+				o := &getOptions(rclient.tracerName).Redis
+				statement := redisStatement(o, cmd.Args())
+				tags := spanTags{
+					{Key: string(ext.DBType), Value: "redis"},
+					{Key: string(ext.DBStatement), Value: statement},
+				}
+				span := tracer.StartSpan(spanName, opentracing.ChildOf(parentCtx), tags)
 				defer span.Finish()
 
 				span.LogFields(log.Object("Redis Cmd", cmd.Name()))
-				span.LogFields(log.Object("Redis Cmd", cmd.Args()))
-				err := oldProcess(cmd)
+				span.LogFields(log.Object("Redis Cmd", statement))
+				start := time.Now()
+				// 注入错误时不发送命令
+				err := injectFault(rclient.Client.Context(), rclient.tracerName, span, faultRedis, cmd.Name())
+				if err != nil {
+					if !setRedisCmdErr(cmd, err) {
+						grpclog.Errorf("redis: cannot set injected error on %T, check setRedisCmdErr after upgrading go-redis", cmd)
+					}
+				} else {
+					err = oldProcess(cmd)
+				}
 				if err != nil {
-					ext.Error.Set(span, true)
-					span.LogFields(log.String("event", "error"), log.String("message", err.Error()))
+					setSpanError(rclient.Client.Context(), rclient.tracerName, span, err)
 				}
+				markSlow(span, tags, &o.Slow, time.Since(start), func() string {
+					return redisFullStatement(o, cmd.Args())
+				})
 
 				return err
 			}
@@ -59,3 +77,30 @@ func (rclient *RedisClient) process() func(oldProcess func(cmd redis.Cmder) erro
 		}
 	}
 }
+
+// redisErrSetter go-redis v7 起，命令实现了 SetErr
+type redisErrSetter interface {
+	SetErr(e error)
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// setRedisCmdErr 设置命令的错误，调用方通过 cmd.Err() 、 Result() 得到该错误，设置失败时返回 false
+// go-redis v6 没有导出 setErr ，也没有按命令返回错误的钩子（ Limiter 属于整个 client ），只能设置所有命令内嵌的 baseCmd.err 。
+// go.mod 固定了 go-redis 的版本，升级后 TestSetRedisCmdErr 会检查该字段是否还在
+func setRedisCmdErr(cmd redis.Cmder, err error) bool {
+	if s, ok := cmd.(redisErrSetter); ok {
+		s.SetErr(err)
+		return true
+	}
+	v := reflect.ValueOf(cmd)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return false
+	}
+	f := v.Elem().FieldByName("err")
+	if !f.IsValid() || f.Type() != errorType {
+		return false
+	}
+	reflect.NewAt(errorType, unsafe.Pointer(f.UnsafeAddr())).Elem().Set(reflect.ValueOf(err))
+	return true
+}
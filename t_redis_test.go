@@ -0,0 +1,38 @@
+package tracer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-redis/redis"
+)
+
+// TestSetRedisCmdErr 注入的错误要能设置到各种命令上
+// 失败说明 go-redis 改了 baseCmd ，注入的错误调用方将看不到，需要修改 setRedisCmdErr
+func TestSetRedisCmdErr(t *testing.T) {
+	cmds := []redis.Cmder{
+		redis.NewCmd("get", "key"),
+		redis.NewStringCmd("get", "key"),
+		redis.NewStatusCmd("set", "key", "value"),
+		redis.NewIntCmd("incr", "key"),
+		redis.NewBoolCmd("expire", "key", 1),
+		redis.NewFloatCmd("incrbyfloat", "key", 1),
+		redis.NewSliceCmd("mget", "key"),
+		redis.NewStringSliceCmd("keys", "*"),
+		redis.NewStringStringMapCmd("hgetall", "key"),
+		redis.NewZSliceCmd("zrange", "key", 0, -1, "withscores"),
+		redis.NewDurationCmd(0, "ttl", "key"),
+	}
+	injected := errors.New("fault: drill")
+	for _, cmd := range cmds {
+		if !setRedisCmdErr(cmd, injected) {
+			t.Fatalf("%T: cannot set error, go-redis changed baseCmd.err", cmd)
+		}
+		if cmd.Err() != injected {
+			t.Fatalf("%T: got %v, want injected error", cmd, cmd.Err())
+		}
+	}
+	if setRedisCmdErr(nil, injected) {
+		t.Error("got true for a nil command")
+	}
+}
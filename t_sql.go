@@ -0,0 +1,551 @@
+package tracer
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+// database/sql 驱动封装
+// 包装任意 driver.Driver ，对 Conn/Stmt/Tx/Rows 的操作创建 span
+//
+// 使用方法一：
+//   tracer.RegisterDriver("mysql-traced", mysql.MySQLDriver{}, tracerName)
+//   db, err := sql.Open("mysql-traced", dsn)
+//
+// 使用方法二：
+//   db, err := tracer.OpenDB(mysql.MySQLDriver{}, dsn, tracerName)
+
+// RegisterDriver 注册一个带追踪的 database/sql 驱动
+// 驱动拿不到 sql.Open 返回的 db ， span 不记录连接池统计，需要对 db 调用 WatchDBStats
+func RegisterDriver(driverName string, d driver.Driver, tracerName string) {
+	sql.Register(driverName, &sqlDriver{Driver: d, tracerName: tracerName})
+}
+
+// OpenDB 使用带追踪的驱动，打开数据库
+func OpenDB(d driver.Driver, dsn, tracerName string) (*sql.DB, error) {
+	c, err := (&sqlDriver{Driver: d, tracerName: tracerName}).OpenConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	db := sql.OpenDB(c)
+	c.(*sqlConnector).db.Store(db)
+	return db, nil
+}
+
+type sqlDriver struct {
+	driver.Driver
+	tracerName string
+}
+
+func (d *sqlDriver) Open(dsn string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return d.wrapConn(conn, dsn, nil), nil
+}
+
+func (d *sqlDriver) wrapConn(conn driver.Conn, dsn string, connector *sqlConnector) *sqlConn {
+	return &sqlConn{Conn: conn, tracerName: d.tracerName, instance: dsnInstance(dsn), connector: connector}
+}
+
+func (d *sqlDriver) OpenConnector(dsn string) (driver.Connector, error) {
+	c := &sqlConnector{driver: d, dsn: dsn}
+	if dc, ok := d.Driver.(driver.DriverContext); ok {
+		var err error
+		if c.connector, err = dc.OpenConnector(dsn); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+type sqlConnector struct {
+	driver    *sqlDriver
+	connector driver.Connector
+	dsn       string
+	db        atomic.Value // *sql.DB ，用于记录连接池统计
+}
+
+func (c *sqlConnector) Connect(ctx context.Context) (conn driver.Conn, err error) {
+	if c.connector == nil {
+		conn, err = c.driver.Driver.Open(c.dsn)
+	} else {
+		conn, err = c.connector.Connect(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c.driver.wrapConn(conn, c.dsn, c), nil
+}
+
+// Driver 返回与本 connector 绑定的驱动，使 sql.DB.Driver() 能找回 connector
+func (c *sqlConnector) Driver() driver.Driver {
+	return &connectorDriver{c}
+}
+
+// dbStats 获取连接池统计， db 未知时返回 false
+func (c *sqlConnector) dbStats() (stats sql.DBStats, ok bool) {
+	if db, _ := c.db.Load().(*sql.DB); db != nil {
+		return db.Stats(), true
+	}
+	return
+}
+
+type connectorDriver struct {
+	*sqlConnector
+}
+
+func (d *connectorDriver) Open(dsn string) (driver.Conn, error) {
+	return d.driver.Open(dsn)
+}
+
+// sqlConn
+
+type sqlConn struct {
+	driver.Conn
+	tracerName string
+	instance   string
+	connector  *sqlConnector
+
+	// 连接池等待，见 t_sql_stats.go 。 database/sql 保证同一时刻只有一个 goroutine 使用连接
+	released    time.Duration
+	hasReleased bool
+	wait        *time.Duration
+}
+
+// startSQLSpan 创建 SQL span ，tracer 未打开时返回 nil
+func (conn *sqlConn) startSQLSpan(ctx context.Context, operation, query string, args []driver.NamedValue, start time.Time) (opentracing.Span, spanTags) {
+	tracer := Get(conn.tracerName)
+	if tracer == nil {
+		return nil, nil
+	}
+	var parentCtx opentracing.SpanContext
+	if parent := opentracing.SpanFromContext(ctx); parent != nil {
+		parentCtx = parent.Context()
+	}
+	o := &getOptions(conn.tracerName).SQL
+	tags := spanTags{
+		{Key: string(ext.Component), Value: "database/sql"},
+		ext.SpanKindRPCClient,
+		{Key: string(ext.DBType), Value: "sql"},
+		{Key: string(ext.DBInstance), Value: conn.instance},
+	}
+	if query != "" {
+		tags = append(tags, opentracing.Tag{Key: string(ext.DBStatement), Value: sqlStatement(o, query)})
+	}
+	span := tracer.StartSpan(
+		"SQL "+operation,
+		opentracing.ChildOf(parentCtx),
+		opentracing.StartTime(start),
+		tags,
+	)
+	if o.LogParams && len(args) > 0 {
+		span.LogFields(log.String("db.params", sqlParams(o, args)))
+	}
+	return span, tags
+}
+
+// sqlCall 一次 SQL 操作的 span
+type sqlCall struct {
+	conn  *sqlConn
+	ctx   context.Context
+	span  opentracing.Span
+	tags  spanTags
+	query string
+	args  []driver.NamedValue
+	start time.Time
+}
+
+// traceSQL 执行 fn ，并用 span 记录执行结果
+// fn 返回 driver.ErrSkip 时，不记录 span ， database/sql 会改用其他方式执行
+func (conn *sqlConn) traceSQL(ctx context.Context, operation, query string, args []driver.NamedValue, fn func() error) error {
+	call, err := conn.startSQL(ctx, operation, query, args, fn)
+	if call != nil {
+		call.finish(err)
+	}
+	return err
+}
+
+// querySQL 执行查询， span 在 Rows.Close 时结束，包含行迭代的时间
+func (conn *sqlConn) querySQL(ctx context.Context, query string, args []driver.NamedValue, fn func() (driver.Rows, error)) (driver.Rows, error) {
+	var rows driver.Rows
+	call, err := conn.startSQL(ctx, "QUERY", query, args, func() (err error) {
+		rows, err = fn()
+		return err
+	})
+	if err != nil {
+		if call != nil {
+			call.finish(err)
+		}
+		return nil, err
+	}
+	if call == nil {
+		return rows, nil
+	}
+	return &sqlRows{Rows: rows, call: call}, nil
+}
+
+// startSQL 执行 fn ，创建 span 但不结束
+// tracer 未打开、 fn 返回 driver.ErrSkip 时，返回 nil
+func (conn *sqlConn) startSQL(ctx context.Context, operation, query string, args []driver.NamedValue, fn func() error) (*sqlCall, error) {
+	var rules []*faultRule
+	if parent := opentracing.SpanFromContext(ctx); parent != nil {
+		rules = faultsFor(conn.tracerName, parent.Context(), faultSQL, operation)
+	}
+	start := time.Now()
+	err := applyFaults(ctx, rules)
+	if err == nil {
+		err = fn()
+	}
+	if err == driver.ErrSkip {
+		return nil, err
+	}
+	span, tags := conn.startSQLSpan(ctx, operation, query, args, start)
+	if span == nil {
+		return nil, err
+	}
+	tagFaults(span, rules)
+	if stats, ok := conn.dbStats(); ok {
+		setDBStatsTags(span, stats)
+		conn.setPoolWaitTags(span)
+	}
+	return &sqlCall{conn: conn, ctx: ctx, span: span, tags: tags, query: query, args: args, start: start}, err
+}
+
+// finish 检查慢操作，结束 span
+func (c *sqlCall) finish(err error) {
+	o := &getOptions(c.conn.tracerName).SQL
+	markSlow(c.span, c.tags, &o.Slow, time.Since(c.start), func() string {
+		return sqlFullStatement(o, c.query, c.args)
+	})
+	finishSQLSpan(c.ctx, c.conn.tracerName, c.span, err)
+	c.conn.releaseWait()
+}
+
+func (conn *sqlConn) dbStats() (sql.DBStats, bool) {
+	if conn.connector == nil || Get(conn.tracerName) == nil {
+		return sql.DBStats{}, false
+	}
+	return conn.connector.dbStats()
+}
+
+func finishSQLSpan(ctx context.Context, tracerName string, span opentracing.Span, err error) {
+	if err != nil {
+		setSpanError(ctx, tracerName, span, err)
+	}
+	span.Finish()
+}
+
+func (conn *sqlConn) Prepare(query string) (driver.Stmt, error) {
+	return conn.PrepareContext(context.Background(), query)
+}
+
+func (conn *sqlConn) PrepareContext(ctx context.Context, query string) (stmt driver.Stmt, err error) {
+	err = conn.traceSQL(ctx, "PREPARE", query, nil, func() error {
+		if c, ok := conn.Conn.(driver.ConnPrepareContext); ok {
+			stmt, err = c.PrepareContext(ctx, query)
+		} else {
+			stmt, err = conn.Conn.Prepare(query)
+			if err == nil {
+				select {
+				case <-ctx.Done():
+					stmt.Close()
+					return ctx.Err()
+				default:
+				}
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &sqlStmt{Stmt: stmt, conn: conn, query: query}, nil
+}
+
+func (conn *sqlConn) Begin() (driver.Tx, error) {
+	return conn.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (conn *sqlConn) BeginTx(ctx context.Context, opts driver.TxOptions) (tx driver.Tx, err error) {
+	err = conn.traceSQL(ctx, "BEGIN", "", nil, func() error {
+		if c, ok := conn.Conn.(driver.ConnBeginTx); ok {
+			tx, err = c.BeginTx(ctx, opts)
+			return err
+		}
+		if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) {
+			return errors.New("sql: driver does not support non-default isolation level")
+		}
+		if opts.ReadOnly {
+			return errors.New("sql: driver does not support read-only transactions")
+		}
+		tx, err = conn.Conn.Begin()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &sqlTx{Tx: tx, conn: conn, ctx: ctx}, nil
+}
+
+func (conn *sqlConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (result driver.Result, err error) {
+	err = conn.traceSQL(ctx, "EXEC", query, args, func() error {
+		switch c := conn.Conn.(type) {
+		case driver.ExecerContext:
+			result, err = c.ExecContext(ctx, query, args)
+		case driver.Execer:
+			var values []driver.Value
+			if values, err = namedValuesToValues(args); err != nil {
+				return err
+			}
+			result, err = c.Exec(query, values)
+		default:
+			return driver.ErrSkip
+		}
+		return err
+	})
+	return result, err
+}
+
+func (conn *sqlConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return conn.querySQL(ctx, query, args, func() (driver.Rows, error) {
+		switch c := conn.Conn.(type) {
+		case driver.QueryerContext:
+			return c.QueryContext(ctx, query, args)
+		case driver.Queryer:
+			values, err := namedValuesToValues(args)
+			if err != nil {
+				return nil, err
+			}
+			return c.Query(query, values)
+		}
+		return nil, driver.ErrSkip
+	})
+}
+
+func (conn *sqlConn) Ping(ctx context.Context) error {
+	p, ok := conn.Conn.(driver.Pinger)
+	if !ok {
+		return nil
+	}
+	return conn.traceSQL(ctx, "PING", "", nil, func() error {
+		return p.Ping(ctx)
+	})
+}
+
+// ResetSession 连接从连接池取出、再次使用前调用
+func (conn *sqlConn) ResetSession(ctx context.Context) error {
+	conn.grabWait()
+	if r, ok := conn.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (conn *sqlConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if c, ok := conn.Conn.(driver.NamedValueChecker); ok {
+		return c.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// sqlStmt
+
+type sqlStmt struct {
+	driver.Stmt
+	conn  *sqlConn
+	query string
+}
+
+func (s *sqlStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), valuesToNamedValues(args))
+}
+
+func (s *sqlStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (result driver.Result, err error) {
+	err = s.conn.traceSQL(ctx, "EXEC", s.query, args, func() error {
+		if c, ok := s.Stmt.(driver.StmtExecContext); ok {
+			result, err = c.ExecContext(ctx, args)
+			return err
+		}
+		var values []driver.Value
+		if values, err = namedValuesToValues(args); err != nil {
+			return err
+		}
+		result, err = s.Stmt.Exec(values)
+		return err
+	})
+	return result, err
+}
+
+func (s *sqlStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), valuesToNamedValues(args))
+}
+
+func (s *sqlStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.querySQL(ctx, s.query, args, func() (driver.Rows, error) {
+		if c, ok := s.Stmt.(driver.StmtQueryContext); ok {
+			return c.QueryContext(ctx, args)
+		}
+		values, err := namedValuesToValues(args)
+		if err != nil {
+			return nil, err
+		}
+		return s.Stmt.Query(values)
+	})
+}
+
+// CheckNamedValue 优先使用 Stmt 的 NamedValueChecker ，其次使用 Conn 的，与 database/sql 的查找顺序一致
+func (s *sqlStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if c, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return c.CheckNamedValue(nv)
+	}
+	if c, ok := s.conn.Conn.(driver.NamedValueChecker); ok {
+		return c.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// ColumnConverter 转发 Stmt 的 ColumnConverter ，没有实现时使用默认的转换
+func (s *sqlStmt) ColumnConverter(idx int) driver.ValueConverter {
+	if c, ok := s.Stmt.(driver.ColumnConverter); ok {
+		return c.ColumnConverter(idx)
+	}
+	return driver.DefaultParameterConverter
+}
+
+// sqlTx
+
+type sqlTx struct {
+	driver.Tx
+	conn *sqlConn
+	ctx  context.Context
+}
+
+func (tx *sqlTx) Commit() error {
+	return tx.conn.traceSQL(tx.ctx, "COMMIT", "", nil, tx.Tx.Commit)
+}
+
+func (tx *sqlTx) Rollback() error {
+	return tx.conn.traceSQL(tx.ctx, "ROLLBACK", "", nil, tx.Tx.Rollback)
+}
+
+// sqlRows 记录行迭代，在 Close 时结束查询的 span
+
+type sqlRows struct {
+	driver.Rows
+	call  *sqlCall
+	count int
+	err   error
+}
+
+func (r *sqlRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	if err == nil {
+		r.count++
+	} else if err != io.EOF {
+		r.err = err
+	}
+	return err
+}
+
+func (r *sqlRows) Close() error {
+	err := r.Rows.Close()
+	if err == nil {
+		err = r.err
+	}
+	r.call.span.LogFields(log.Int("rows", r.count))
+	r.call.finish(err)
+	return err
+}
+
+func (r *sqlRows) HasNextResultSet() bool {
+	if rs, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return rs.HasNextResultSet()
+	}
+	return false
+}
+
+func (r *sqlRows) NextResultSet() error {
+	if rs, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return rs.NextResultSet()
+	}
+	return io.EOF
+}
+
+func (r *sqlRows) ColumnTypeScanType(index int) reflect.Type {
+	if c, ok := r.Rows.(driver.RowsColumnTypeScanType); ok {
+		return c.ColumnTypeScanType(index)
+	}
+	return reflect.TypeOf(new(interface{})).Elem()
+}
+
+func (r *sqlRows) ColumnTypeDatabaseTypeName(index int) string {
+	if c, ok := r.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return c.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+func (r *sqlRows) ColumnTypeLength(index int) (length int64, ok bool) {
+	if c, ok := r.Rows.(driver.RowsColumnTypeLength); ok {
+		return c.ColumnTypeLength(index)
+	}
+	return 0, false
+}
+
+func (r *sqlRows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	if c, ok := r.Rows.(driver.RowsColumnTypeNullable); ok {
+		return c.ColumnTypeNullable(index)
+	}
+	return false, false
+}
+
+func (r *sqlRows) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
+	if c, ok := r.Rows.(driver.RowsColumnTypePrecisionScale); ok {
+		return c.ColumnTypePrecisionScale(index)
+	}
+	return 0, 0, false
+}
+
+// dsnInstance 从 DSN 中取出数据库名，作为 db.instance
+// 例如 root:123456@tcp(127.0.0.1:3306)/mysql?charset=utf8 取出 mysql
+func dsnInstance(dsn string) string {
+	if i := strings.IndexByte(dsn, '?'); i >= 0 {
+		dsn = dsn[:i]
+	}
+	if i := strings.LastIndexByte(dsn, '/'); i >= 0 {
+		dsn = dsn[i+1:]
+	}
+	return dsn
+}
+
+func namedValuesToValues(named []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(named))
+	for i, nv := range named {
+		if nv.Name != "" {
+			return nil, errors.New("sql: driver does not support the use of Named Parameters")
+		}
+		values[i] = nv.Value
+	}
+	return values, nil
+}
+
+func valuesToNamedValues(values []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(values))
+	for i, v := range values {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return named
+}
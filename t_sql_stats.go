@@ -0,0 +1,110 @@
+package tracer
+
+import (
+	"database/sql"
+	"expvar"
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+)
+
+// database/sql 连接池统计
+// 每个 SQL span 记录执行时连接池的使用情况，用于判断连接池是否已满
+// 同时可以定期导出到 expvar ，在 /debug/vars 查看等待连接池分配连接的次数、总时间
+//
+// 连接从连接池取出后的第一个 span 记录取连接时的等待：
+//   db.pool.wait_count 本次取连接是否等待（ 0 或 1 ）
+//   db.pool.wait_ms    等待时间
+// database/sql 不告诉驱动调用方等了多久，这里取该连接上次操作结束到被取出（ ResetSession ）期间连接池等待时间的增量。
+// 连接归还时直接交给等待中的调用方，增量即为该调用方的等待；多个连接同时被等待时，会包含其他调用方的等待。
+// 等待次数在开始等待时就已累加，增量不对应本次操作，所以不用
+
+// sqlStatsVars 导出的连接池统计， key 为 WatchDBStats 的 name 参数
+var sqlStatsVars = expvar.NewMap("tracer.sql")
+
+// WatchDBStats 定期导出 db 的连接池统计到 expvar （ tracer.sql.<name> ）
+// 如果 db 由 RegisterDriver 注册的驱动打开，之后的 span 也会记录连接池统计（ OpenDB 打开的 db 不需要调用也会记录）
+func WatchDBStats(db *sql.DB, name string, interval time.Duration) (stop func()) {
+	if d, ok := db.Driver().(*connectorDriver); ok {
+		d.db.Store(db)
+	}
+	v := new(expvar.Map).Init()
+	sqlStatsVars.Set(name, v)
+	publishDBStats(v, db.Stats())
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				publishDBStats(v, db.Stats())
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+func publishDBStats(v *expvar.Map, stats sql.DBStats) {
+	setInt := func(key string, value int64) {
+		x := new(expvar.Int)
+		x.Set(value)
+		v.Set(key, x)
+	}
+	setInt("max_open", int64(stats.MaxOpenConnections))
+	setInt("open", int64(stats.OpenConnections))
+	setInt("in_use", int64(stats.InUse))
+	setInt("idle", int64(stats.Idle))
+	setInt("wait_count", stats.WaitCount)
+	setInt("wait_duration_ms", stats.WaitDuration.Milliseconds())
+	setInt("max_idle_closed", stats.MaxIdleClosed)
+	setInt("max_lifetime_closed", stats.MaxLifetimeClosed)
+}
+
+// releaseWait 连接上的操作结束时调用，记下连接池当前的累计等待时间
+func (conn *sqlConn) releaseWait() {
+	if stats, ok := conn.dbStats(); ok {
+		conn.released = stats.WaitDuration
+		conn.hasReleased = true
+	}
+}
+
+// grabWait 连接从连接池取出时调用，计算归还后连接池等待时间的增量，由下一个 span 记录
+func (conn *sqlConn) grabWait() {
+	if !conn.hasReleased {
+		return
+	}
+	if stats, ok := conn.dbStats(); ok {
+		wait := stats.WaitDuration - conn.released
+		conn.wait = &wait
+	}
+}
+
+// setPoolWaitTags 记录取连接时的等待，每次取出只记录一次
+func (conn *sqlConn) setPoolWaitTags(span opentracing.Span) {
+	if conn.wait == nil {
+		return
+	}
+	count := 0
+	if *conn.wait > 0 {
+		count = 1
+	}
+	span.SetTag("db.pool.wait_count", count)
+	span.SetTag("db.pool.wait_ms", conn.wait.Milliseconds())
+	conn.wait = nil
+}
+
+// setDBStatsTags 在 span 上记录连接池的使用情况
+func setDBStatsTags(span opentracing.Span, stats sql.DBStats) {
+	span.SetTag("db.pool.in_use", stats.InUse)
+	span.SetTag("db.pool.idle", stats.Idle)
+	span.SetTag("db.pool.open", stats.OpenConnections)
+	span.SetTag("db.pool.max_open", stats.MaxOpenConnections)
+}
@@ -0,0 +1,110 @@
+package tracer_test
+
+import (
+	"database/sql"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/fananchong/tracer"
+	"github.com/fananchong/tracer/tracetest"
+)
+
+// TestSQLPoolWait 连接池只有一个连接，第二个调用方等待第一个事务提交
+func TestSQLPoolWait(t *testing.T) {
+	rec := tracetest.Start(t, "sql")
+	db, mock := openMockDB(t)
+	db.SetMaxOpenConns(1)
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	mock.ExpectExec("UPDATE user SET level = 1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE user SET level = 2").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() {
+		_, err := db.Exec("UPDATE user SET level = 1")
+		done <- err
+	}()
+	for db.Stats().WaitCount == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("UPDATE user SET level = 2"); err != nil {
+		t.Fatal(err)
+	}
+
+	got := rec.Spans()
+	if len(got) != 4 {
+		t.Fatalf("got %d spans, want 4", len(got))
+	}
+	for _, s := range got[:2] {
+		if s.Tag("db.pool.wait_count") != "" || s.Tag("db.pool.in_use") == "" {
+			t.Errorf("%s: got wait_count %v in_use %v", s.Operation, s.Tag("db.pool.wait_count"), s.Tag("db.pool.in_use"))
+		}
+	}
+	s := got[2]
+	if s.Operation != "SQL EXEC" || s.Tag("db.pool.wait_count") != "1" {
+		t.Fatalf("got %s wait_count %v, want 1", s.Operation, s.Tag("db.pool.wait_count"))
+	}
+	if ms, _ := strconv.Atoi(s.Tag("db.pool.wait_ms")); ms < 50 || ms > 1000 {
+		t.Errorf("got wait_ms %v, want about 50", s.Tag("db.pool.wait_ms"))
+	}
+	// 空闲连接直接取出，没有等待
+	if s := got[3]; s.Tag("db.pool.wait_count") != "0" || s.Tag("db.pool.wait_ms") != "0" {
+		t.Errorf("got wait_count %v wait_ms %v, want 0", s.Tag("db.pool.wait_count"), s.Tag("db.pool.wait_ms"))
+	}
+}
+
+// registerOnce sqlmock 的驱动是全局的，只注册一次（ -count 多次运行）
+var registerOnce sync.Once
+
+// TestSQLRegisterDriverStats RegisterDriver 注册的驱动，调用 WatchDBStats 后才记录连接池统计
+func TestSQLRegisterDriverStats(t *testing.T) {
+	rec := tracetest.Start(t, "sql")
+	dsn := "sqlmock_" + t.Name()
+	mockDB, mock, err := sqlmock.NewWithDSN(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mockDB.Close()
+	registerOnce.Do(func() { tracer.RegisterDriver("sqlmock-traced", mockDB.Driver(), "sql") })
+	db, err := sql.Open("sqlmock-traced", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mock.ExpectExec("DELETE FROM user").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM user").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if _, err := db.Exec("DELETE FROM user"); err != nil {
+		t.Fatal(err)
+	}
+	stop := tracer.WatchDBStats(db, "register_driver", time.Hour)
+	defer stop()
+	if _, err := db.Exec("DELETE FROM user"); err != nil {
+		t.Fatal(err)
+	}
+
+	got := rec.Spans()
+	if len(got) != 2 {
+		t.Fatalf("got %d spans, want 2", len(got))
+	}
+	if v := got[0].Tag("db.pool.in_use"); v != "" {
+		t.Errorf("got in_use %v before WatchDBStats", v)
+	}
+	if v := got[1].Tag("db.pool.in_use"); v != "1" {
+		t.Errorf("got in_use %v after WatchDBStats, want 1", v)
+	}
+}
@@ -0,0 +1,172 @@
+package tracer_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/fananchong/tracer"
+	"github.com/fananchong/tracer/tracetest"
+)
+
+// openMockDB 用 sqlmock 打开带追踪的 db
+func openMockDB(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	dsn := "sqlmock_" + t.Name()
+	mockDB, mock, err := sqlmock.NewWithDSN(dsn, sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { mockDB.Close() })
+	db, err := tracer.OpenDB(mockDB.Driver(), dsn, "sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, mock
+}
+
+func TestSQLQueryOneSpan(t *testing.T) {
+	rec := tracetest.Start(t, "sql")
+	db, mock := openMockDB(t)
+	mock.ExpectQuery("SELECT id FROM user WHERE level > ?").
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+
+	rows, err := db.QueryContext(context.Background(), "SELECT id FROM user WHERE level > ?", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.Spans()) != 0 {
+		t.Fatal("query span finished before Rows.Close")
+	}
+	n := 0
+	for rows.Next() {
+		n++
+	}
+	rows.Close()
+
+	got := rec.Spans()
+	if len(got) != 1 {
+		t.Fatalf("got %d spans, want 1", len(got))
+	}
+	s := got[0]
+	if s.Operation != "SQL QUERY" || s.Tag("db.statement") != "SELECT id FROM user WHERE level > ?" {
+		t.Errorf("got span %s %q", s.Operation, s.Tag("db.statement"))
+	}
+	if len(s.Logs) == 0 || fmt.Sprint(s.Logs[len(s.Logs)-1].Fields["rows"]) != "2" || n != 2 {
+		t.Errorf("rows not logged: %v", s.Logs)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSQLExecError(t *testing.T) {
+	rec := tracetest.Start(t, "sql")
+	db, mock := openMockDB(t)
+	mock.ExpectExec("UPDATE user SET level = 1").WillReturnError(errors.New("boom"))
+
+	if _, err := db.Exec("UPDATE user SET level = 1"); err == nil {
+		t.Fatal("want error")
+	}
+	got := rec.Spans()
+	if len(got) != 1 || got[0].Operation != "SQL EXEC" || !got[0].Error() {
+		t.Fatalf("got %+v", got)
+	}
+}
+
+// level 需要驱动转换的参数类型
+type level struct{ n int }
+
+type levelConverter struct{}
+
+func (levelConverter) ConvertValue(v interface{}) (driver.Value, error) {
+	if l, ok := v.(level); ok {
+		return int64(l.n), nil
+	}
+	return driver.DefaultParameterConverter.ConvertValue(v)
+}
+
+// TestSQLStmtNamedValueChecker Stmt 没有实现 NamedValueChecker 时，使用 Conn 的
+func TestSQLStmtNamedValueChecker(t *testing.T) {
+	tracetest.Start(t, "sql")
+	dsn := "sqlmock_" + t.Name()
+	mockDB, mock, err := sqlmock.NewWithDSN(dsn, sqlmock.ValueConverterOption(levelConverter{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mockDB.Close()
+	db, err := tracer.OpenDB(mockDB.Driver(), dsn, "sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	mock.ExpectPrepare("UPDATE user").ExpectExec().WithArgs(int64(3)).WillReturnResult(sqlmock.NewResult(0, 1))
+	stmt, err := db.Prepare("UPDATE user SET level = ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	if _, err := stmt.Exec(level{3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestSQLSlowRedacted 慢查询的完整语句为按 Redact 脱敏的原始语句，不带绑定参数；
+// 强制采样时，未采样的 span 也记录，并保留开始时设置的 tag
+func TestSQLSlowRedacted(t *testing.T) {
+	rec := tracetest.Start(t, "sql")
+	tracer.SetOptions("sql", tracer.Options{
+		SQL: tracer.SQLOptions{
+			Slow:   tracer.SlowOptions{Threshold: time.Nanosecond, ForceSample: true},
+			Redact: []tracer.RedactRule{{Pattern: regexp.MustCompile(`'[^']*'`)}},
+		},
+	})
+	if err := tracer.SetSamplingRate("sql", 0); err != nil {
+		t.Fatal(err)
+	}
+	db, mock := openMockDB(t)
+	mock.ExpectQuery("SELECT id FROM user WHERE password = 'hunter2' AND email = ? LIMIT 1").
+		WithArgs("alice@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	rows, err := db.Query("SELECT id FROM user WHERE password = 'hunter2' AND email = ? LIMIT 1", "alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+
+	got := rec.Spans()
+	if len(got) != 1 {
+		t.Fatalf("got %d spans, want 1 (slow span should be force sampled)", len(got))
+	}
+	s := got[0]
+	full := s.Tag("db.statement.full")
+	if s.Tag("slow") != "true" || full != "SELECT id FROM user WHERE password = *** AND email = ? LIMIT 1" {
+		t.Errorf("got slow=%s db.statement.full=%q", s.Tag("slow"), full)
+	}
+	for key, want := range map[string]string{
+		"component":    "database/sql",
+		"span.kind":    "client",
+		"db.type":      "sql",
+		"db.statement": "SELECT id FROM user WHERE password = ? AND email = ? LIMIT ?",
+	} {
+		if got := s.Tag(key); got != want {
+			t.Errorf("got %s=%q, want %q", key, got, want)
+		}
+	}
+	if s.Tag("db.instance") == "" {
+		t.Error("db.instance dropped")
+	}
+}
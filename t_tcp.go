@@ -0,0 +1,475 @@
+package tracer
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+	"google.golang.org/grpc/grpclog"
+)
+
+// 自定义 TCP 协议的追踪
+// 游戏服务器之间通常使用分帧的 TCP 协议，这里提供：
+//   - BinaryCarrier ： 紧凑的二进制 carrier ，可以放到任意消息头中
+//   - InjectBinary/ExtractBinary ： 发送时写入、接收时读出 span context
+//   - TCPClient/ServeTCP ： 基于 net.Conn 的请求/应答循环，产生与 gRPC 拦截器一样的 client/server span
+
+// BinaryCarrier 紧凑的二进制 carrier
+// 编码格式： uvarint(个数) + [uvarint(len) key uvarint(len) value]...
+type BinaryCarrier map[string]string
+
+// Set 实现 opentracing.TextMapWriter
+func (c BinaryCarrier) Set(key, val string) {
+	c[key] = val
+}
+
+// ForeachKey 实现 opentracing.TextMapReader
+func (c BinaryCarrier) ForeachKey(handler func(key, val string) error) error {
+	for k, v := range c {
+		if err := handler(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalBinary 编码为 []byte
+func (c BinaryCarrier) MarshalBinary() ([]byte, error) {
+	keys := make([]string, 0, len(c))
+	size := binary.MaxVarintLen64
+	for k, v := range c {
+		keys = append(keys, k)
+		size += 2*binary.MaxVarintLen64 + len(k) + len(v)
+	}
+	sort.Strings(keys)
+	buf := make([]byte, 0, size)
+	buf = appendUvarint(buf, uint64(len(keys)))
+	for _, k := range keys {
+		buf = appendUvarint(buf, uint64(len(k)))
+		buf = append(buf, k...)
+		buf = appendUvarint(buf, uint64(len(c[k])))
+		buf = append(buf, c[k]...)
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary 从 []byte 解码，数据被截断或有多余的字节时返回错误
+func (c BinaryCarrier) UnmarshalBinary(data []byte) error {
+	n, data, err := readUvarint(data)
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < n; i++ {
+		var k, v []byte
+		if k, data, err = readBytes(data); err != nil {
+			return err
+		}
+		if v, data, err = readBytes(data); err != nil {
+			return err
+		}
+		c[string(k)] = string(v)
+	}
+	if len(data) != 0 {
+		return errBinaryCarrier
+	}
+	return nil
+}
+
+var errBinaryCarrier = errors.New("tracer: corrupted binary carrier")
+
+func appendUvarint(buf []byte, x uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], x)
+	return append(buf, tmp[:n]...)
+}
+
+func readUvarint(data []byte) (uint64, []byte, error) {
+	x, n := binary.Uvarint(data)
+	if n <= 0 {
+		return 0, nil, errBinaryCarrier
+	}
+	return x, data[n:], nil
+}
+
+func readBytes(data []byte) ([]byte, []byte, error) {
+	n, data, err := readUvarint(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if uint64(len(data)) < n {
+		return nil, nil, errBinaryCarrier
+	}
+	return data[:n], data[n:], nil
+}
+
+// InjectBinary 把 ctx 中的 span context 编码为 []byte ，用于自定义协议的消息头
+// tracer 未打开，或者 ctx 中没有 span 时，返回 nil
+func InjectBinary(ctx context.Context, tracerName string) ([]byte, error) {
+	tracer := Get(tracerName)
+	if tracer == nil {
+		return nil, nil
+	}
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil {
+		return nil, nil
+	}
+	return injectBinary(tracerName, tracer, span.Context())
+}
+
+// ExtractBinary 从自定义协议的消息头中解码 span context
+func ExtractBinary(tracerName string, header []byte) (opentracing.SpanContext, error) {
+	tracer := Get(tracerName)
+	if tracer == nil {
+		return nil, nil
+	}
+	return extractBinary(tracerName, tracer, header)
+}
+
+func injectBinary(tracerName string, tracer opentracing.Tracer, sc opentracing.SpanContext) ([]byte, error) {
+	carrier := BinaryCarrier{}
+	if err := injectTo(tracerName, tracer, sc, opentracing.TextMap, carrier); err != nil {
+		return nil, err
+	}
+	return carrier.MarshalBinary()
+}
+
+func extractBinary(tracerName string, tracer opentracing.Tracer, header []byte) (opentracing.SpanContext, error) {
+	if len(header) == 0 {
+		return nil, opentracing.ErrSpanContextNotFound
+	}
+	carrier := BinaryCarrier{}
+	if err := carrier.UnmarshalBinary(header); err != nil {
+		return nil, err
+	}
+	return extractFrom(tracerName, tracer, opentracing.TextMap, carrier)
+}
+
+// 请求/应答帧格式
+//   请求： uint32(长度) uint16(method 长度) method uint16(header 长度) header payload
+//   应答： uint32(长度) uint8(状态， 0 成功， 1 失败) payload/错误信息
+
+const maxTCPFrameSize = 16 << 20
+
+const (
+	tcpStatusOK byte = iota
+	tcpStatusError
+)
+
+// TCPHandler 处理一个请求， ctx 中带有 server span
+type TCPHandler func(ctx context.Context, method string, req []byte) ([]byte, error)
+
+// DefaultTCPTimeout ctx 没有 deadline 时， TCPClient.Call 的超时时间
+const DefaultTCPTimeout = 30 * time.Second
+
+// ErrTCPClientClosed 连接已关闭（ Close ，或者出错后不能重连）
+var ErrTCPClientClosed = errors.New("tracer: tcp client closed")
+
+// aLongTimeAgo 设置为连接的 deadline ，使阻塞的读写立即返回
+var aLongTimeAgo = time.Unix(1, 0)
+
+// TCPClient 带追踪的 TCP 请求/应答客户端
+// 同一时刻只有一个请求在途，多个 goroutine 调用 Call 时排队
+// 写失败、读失败、超时、 ctx 取消后，连接中的帧可能已经错位（迟到的应答），关闭连接；
+// DialTCPClient 创建的客户端在下次 Call 时重新连接， NewTCPClient 创建的客户端之后返回 ErrTCPClientClosed
+type TCPClient struct {
+	// Timeout ctx 没有 deadline 时的超时时间， 0 表示 DefaultTCPTimeout
+	Timeout time.Duration
+
+	conn       net.Conn
+	r          *bufio.Reader
+	dial       func(ctx context.Context) (net.Conn, error)
+	closed     bool
+	tracerName string
+	mu         sync.Mutex
+}
+
+// NewTCPClient TCPClient 构造函数，使用已经建立的连接，出错后不重连
+func NewTCPClient(conn net.Conn, tracerName string) *TCPClient {
+	return &TCPClient{
+		conn:       conn,
+		r:          bufio.NewReader(conn),
+		tracerName: tracerName,
+	}
+}
+
+// DialTCPClient 连接 address ，返回 TCPClient ，出错后下次 Call 重新连接
+func DialTCPClient(ctx context.Context, network, address, tracerName string) (*TCPClient, error) {
+	var d net.Dialer
+	c := &TCPClient{
+		dial: func(ctx context.Context) (net.Conn, error) {
+			return d.DialContext(ctx, network, address)
+		},
+		tracerName: tracerName,
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, _, err := c.connect(ctx); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Close 关闭连接，之后的 Call 返回 ErrTCPClientClosed
+func (c *TCPClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn, c.r = nil, nil
+	return err
+}
+
+// Call 发送请求，等待应答
+func (c *TCPClient) Call(ctx context.Context, method string, req []byte) ([]byte, error) {
+	if tracer := Get(c.tracerName); tracer != nil {
+		var parentCtx opentracing.SpanContext
+		if parent := opentracing.SpanFromContext(ctx); parent != nil {
+			parentCtx = parent.Context()
+		}
+
+		span := tracer.StartSpan(
+			method,
+			opentracing.ChildOf(parentCtx),
+			opentracing.Tag{Key: string(ext.Component), Value: "TCP"},
+			ext.SpanKindRPCClient,
+		)
+		defer span.Finish()
+
+		header, err := injectBinary(c.tracerName, tracer, span.Context())
+		if err != nil {
+			span.LogFields(log.String("event", "Tracer.Inject() failed"), log.Error(err))
+		}
+		resp, err := c.call(ctx, method, header, req, span)
+		if err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(log.String("event", "error"), log.String("message", err.Error()))
+		}
+		return resp, err
+	}
+	return c.call(ctx, method, nil, req, nil)
+}
+
+func (c *TCPClient) call(ctx context.Context, method string, header, req []byte, span opentracing.Span) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	conn, r, err := c.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if span != nil {
+		span.SetTag("peer.address", conn.RemoteAddr().String())
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		timeout := c.Timeout
+		if timeout <= 0 {
+			timeout = DefaultTCPTimeout
+		}
+		deadline = time.Now().Add(timeout)
+	}
+	conn.SetDeadline(deadline)
+	stop := closeOnDone(ctx, conn)
+	err = writeTCPRequest(conn, method, header, req)
+	var status byte
+	var resp []byte
+	if err == nil {
+		status, resp, err = readTCPResponse(r)
+	}
+	stop()
+	if err != nil {
+		// 帧可能已经错位，关闭连接
+		c.conn.Close()
+		c.conn, c.r = nil, nil
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		} else if ok && !time.Now().Before(deadline) {
+			// 连接的 deadline 与 ctx 相同，读写超时可能先于 ctx 结束返回
+			err = context.DeadlineExceeded
+		}
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	if status != tcpStatusOK {
+		return nil, errors.New(string(resp))
+	}
+	return resp, nil
+}
+
+// connect 返回当前连接，连接已关闭时重新连接
+func (c *TCPClient) connect(ctx context.Context) (net.Conn, *bufio.Reader, error) {
+	if c.conn != nil {
+		return c.conn, c.r, nil
+	}
+	if c.closed || c.dial == nil {
+		return nil, nil, ErrTCPClientClosed
+	}
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.conn, c.r = conn, bufio.NewReader(conn)
+	return c.conn, c.r, nil
+}
+
+// closeOnDone ctx 取消时，使 conn 上阻塞的读写立即返回。返回的函数停止监视
+func closeOnDone(ctx context.Context, conn net.Conn) (stop func()) {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(aLongTimeAgo)
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+// ServeTCP 在 conn 上循环读取请求，调用 handler 处理，并写回应答
+// 对端关闭连接时返回 nil
+func ServeTCP(conn net.Conn, tracerName string, handler TCPHandler) error {
+	r := bufio.NewReader(conn)
+	for {
+		method, header, req, err := readTCPRequest(r)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		resp, err := serveTCPRequest(conn, tracerName, handler, method, header, req)
+		status := tcpStatusOK
+		if err != nil {
+			status, resp = tcpStatusError, []byte(err.Error())
+		}
+		if err = writeTCPResponse(conn, status, resp); err != nil {
+			return err
+		}
+	}
+}
+
+func serveTCPRequest(conn net.Conn, tracerName string, handler TCPHandler, method string, header, req []byte) ([]byte, error) {
+	ctx := context.Background()
+	if tracer := Get(tracerName); tracer != nil {
+		spanContext, err := extractBinary(tracerName, tracer, header)
+		if err != nil && err != opentracing.ErrSpanContextNotFound {
+			// 如果 tracer extract 失败，那么跳过追踪
+			grpclog.Errorf("SpanContext Extract Error! %s", err.Error())
+			return handler(ctx, method, req)
+		}
+
+		span := tracer.StartSpan(
+			method,
+			ext.RPCServerOption(spanContext),
+			opentracing.Tag{Key: string(ext.Component), Value: "TCP"},
+			ext.SpanKindRPCServer,
+		)
+		defer span.Finish()
+		span.SetTag("peer.address", conn.RemoteAddr().String())
+
+		resp, err := handler(opentracing.ContextWithSpan(ctx, span), method, req)
+		if err != nil {
+			ext.Error.Set(span, true)
+			span.LogFields(log.String("event", "error"), log.String("message", err.Error()))
+		}
+		return resp, err
+	}
+	return handler(ctx, method, req)
+}
+
+func writeTCPRequest(w io.Writer, method string, header, payload []byte) error {
+	if len(method) > 0xffff || len(header) > 0xffff {
+		return errors.New("tracer: method or header too long")
+	}
+	size := 2 + len(method) + 2 + len(header) + len(payload)
+	if size > maxTCPFrameSize {
+		return errors.New("tracer: frame too large")
+	}
+	buf := make([]byte, 4, 4+size)
+	binary.BigEndian.PutUint32(buf, uint32(size))
+	buf = append(buf, byte(len(method)>>8), byte(len(method)))
+	buf = append(buf, method...)
+	buf = append(buf, byte(len(header)>>8), byte(len(header)))
+	buf = append(buf, header...)
+	buf = append(buf, payload...)
+	_, err := w.Write(buf)
+	return err
+}
+
+func readTCPRequest(r io.Reader) (method string, header, payload []byte, err error) {
+	frame, err := readTCPFrame(r)
+	if err != nil {
+		return
+	}
+	var m []byte
+	if m, frame, err = splitTCPField(frame); err != nil {
+		return
+	}
+	if header, payload, err = splitTCPField(frame); err != nil {
+		return
+	}
+	return string(m), header, payload, nil
+}
+
+func writeTCPResponse(w io.Writer, status byte, payload []byte) error {
+	if len(payload)+1 > maxTCPFrameSize {
+		return errors.New("tracer: frame too large")
+	}
+	buf := make([]byte, 5, 5+len(payload))
+	binary.BigEndian.PutUint32(buf, uint32(1+len(payload)))
+	buf[4] = status
+	buf = append(buf, payload...)
+	_, err := w.Write(buf)
+	return err
+}
+
+func readTCPResponse(r io.Reader) (status byte, payload []byte, err error) {
+	frame, err := readTCPFrame(r)
+	if err != nil {
+		return
+	}
+	if len(frame) == 0 {
+		return 0, nil, errors.New("tracer: corrupted frame")
+	}
+	return frame[0], frame[1:], nil
+}
+
+func readTCPFrame(r io.Reader) ([]byte, error) {
+	var head [4]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(head[:])
+	if size > maxTCPFrameSize {
+		return nil, errors.New("tracer: frame too large")
+	}
+	frame := make([]byte, size)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+func splitTCPField(frame []byte) ([]byte, []byte, error) {
+	if len(frame) < 2 {
+		return nil, nil, errors.New("tracer: corrupted frame")
+	}
+	n := int(frame[0])<<8 | int(frame[1])
+	if len(frame) < 2+n {
+		return nil, nil, errors.New("tracer: corrupted frame")
+	}
+	return frame[2 : 2+n], frame[2+n:], nil
+}
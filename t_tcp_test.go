@@ -0,0 +1,163 @@
+package tracer_test
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fananchong/tracer"
+	"github.com/fananchong/tracer/tracetest"
+)
+
+// startTCPServer 启动 TCP 服务器， slow 方法等待 200ms 后返回，其他方法原样返回请求
+func startTCPServer(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				tracer.ServeTCP(conn, "tcp", func(ctx context.Context, method string, req []byte) ([]byte, error) {
+					if method == "slow" {
+						time.Sleep(200 * time.Millisecond)
+					}
+					return req, nil
+				})
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestBinaryCarrierRoundTrip(t *testing.T) {
+	tests := []tracer.BinaryCarrier{
+		{},
+		{"uber-trace-id": "4bf92f3577b34da6:00f067aa0ba902b7:0:1"},
+		{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "baggage": "user=1", "": "empty key", "empty value": ""},
+		{"long": strings.Repeat("x", 300)},
+	}
+	for _, c := range tests {
+		data, err := c.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := tracer.BinaryCarrier{}
+		if err := got.UnmarshalBinary(data); err != nil {
+			t.Fatalf("%v: %v", c, err)
+		}
+		if !reflect.DeepEqual(got, c) {
+			t.Errorf("got %v, want %v", got, c)
+		}
+	}
+}
+
+// TestBinaryCarrierCorrupted 截断、损坏的数据返回错误，不会 panic
+func TestBinaryCarrierCorrupted(t *testing.T) {
+	data, _ := tracer.BinaryCarrier{"key": "value", "traceparent": "00-01"}.MarshalBinary()
+	for n := 0; n < len(data); n++ {
+		if err := (tracer.BinaryCarrier{}).UnmarshalBinary(data[:n]); err == nil {
+			t.Errorf("truncated to %d bytes: got no error", n)
+		}
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"overlong varint", []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}},
+		{"count too large", []byte{0xff, 0xff, 0xff, 0xff, 0x0f, 1, 'k', 1, 'v'}},
+		{"key length too large", []byte{1, 0xff, 0xff, 0xff, 0xff, 0x0f, 'k'}},
+		{"value length too large", []byte{1, 1, 'k', 5, 'v'}},
+		{"trailing bytes", append(append([]byte{}, data...), 0)},
+	}
+	for _, tt := range tests {
+		if err := (tracer.BinaryCarrier{}).UnmarshalBinary(tt.data); err == nil {
+			t.Errorf("%s: got no error", tt.name)
+		}
+	}
+
+	tracetest.Start(t, "tcp")
+	if _, err := tracer.ExtractBinary("tcp", data[:len(data)-1]); err == nil {
+		t.Error("ExtractBinary: got no error for a truncated header")
+	}
+}
+
+func TestTCPClientTrace(t *testing.T) {
+	rec := tracetest.Start(t, "tcp")
+	c, err := tracer.DialTCPClient(context.Background(), "tcp", startTCPServer(t), "tcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	resp, err := c.Call(context.Background(), "Echo", []byte("hello"))
+	if err != nil || string(resp) != "hello" {
+		t.Fatalf("got %q %v", resp, err)
+	}
+	rec.Wait(2, time.Second)
+	want := "tcp: Echo [kind=client component=TCP]\n  tcp: Echo [kind=server component=TCP]\n"
+	if got := rec.Tree(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+// TestTCPClientRedial 超时后重新连接，下一个请求不会读到上一个请求迟到的应答
+func TestTCPClientRedial(t *testing.T) {
+	c, err := tracer.DialTCPClient(context.Background(), "tcp", startTCPServer(t), "tcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.Call(ctx, "slow", []byte("late")); err != context.DeadlineExceeded {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+	resp, err := c.Call(context.Background(), "Echo", []byte("next"))
+	if err != nil || string(resp) != "next" {
+		t.Fatalf("got %q %v, want next", resp, err)
+	}
+}
+
+// TestTCPClientCancel ctx 没有 deadline 时，取消 ctx 也能中断等待
+func TestTCPClientCancel(t *testing.T) {
+	c, err := tracer.DialTCPClient(context.Background(), "tcp", startTCPServer(t), "tcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(30*time.Millisecond, cancel)
+	start := time.Now()
+	if _, err := c.Call(ctx, "slow", nil); err != context.Canceled {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Errorf("Call returned after %s", elapsed)
+	}
+}
+
+// TestTCPClientTimeout ctx 没有 deadline 时，使用 Timeout ；连接不能重连时，之后返回 ErrTCPClientClosed
+func TestTCPClientTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	c := tracer.NewTCPClient(client, "tcp")
+	c.Timeout = 30 * time.Millisecond
+	if _, err := c.Call(context.Background(), "Echo", nil); err == nil {
+		t.Fatal("want timeout error")
+	}
+	if _, err := c.Call(context.Background(), "Echo", nil); err != tracer.ErrTCPClientClosed {
+		t.Fatalf("got %v, want ErrTCPClientClosed", err)
+	}
+}
@@ -0,0 +1,227 @@
+package tracebrowser
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/fananchong/tracer/spans"
+	"google.golang.org/grpc/grpclog"
+)
+
+// ServeHTTP 实现 http.Handler
+// 不带参数时显示 trace 列表（支持按服务、操作名、耗时、错误、 trace id 搜索），
+// 带 trace 参数时显示该 trace 的瀑布图与 span 详情
+func (r *Recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	q := req.URL.Query()
+	if id := q.Get("trace"); id != "" {
+		t := r.Trace(id)
+		if t == nil {
+			http.Error(w, "trace not found: "+id, http.StatusNotFound)
+			return
+		}
+		if err := traceTemplate.Execute(w, newTracePage(t)); err != nil {
+			grpclog.Errorf("tracebrowser: %s", err.Error())
+		}
+		return
+	}
+	s := spans.Filter{
+		Service:   q.Get("service"),
+		Operation: q.Get("operation"),
+		TraceID:   q.Get("id"),
+		Errors:    q.Get("errors") != "",
+	}
+	s.MinDuration, _ = time.ParseDuration(q.Get("min"))
+	page := &listPage{Search: s, MinDuration: q.Get("min"), Services: r.Services()}
+	traces := r.Traces()
+	for i := len(traces) - 1; i >= 0; i-- {
+		if t := traces[i]; s.Match(t) {
+			page.Traces = append(page.Traces, newTraceSummary(t))
+		}
+	}
+	if err := listTemplate.Execute(w, page); err != nil {
+		grpclog.Errorf("tracebrowser: %s", err.Error())
+	}
+}
+
+type listPage struct {
+	Search      spans.Filter
+	MinDuration string
+	Services    []string
+	Traces      []*traceSummary
+}
+
+type traceSummary struct {
+	ID       string
+	Start    string
+	Root     string
+	Spans    int
+	Services int
+	Duration string
+	Error    bool
+}
+
+func newTraceSummary(t *spans.Trace) *traceSummary {
+	services := make(map[string]bool)
+	for _, s := range t.Spans {
+		services[s.Service] = true
+	}
+	root := t.Spans[0]
+	if roots := t.Tree(); len(roots) > 0 {
+		root = roots[0].Span
+	}
+	return &traceSummary{
+		ID:       t.ID,
+		Start:    t.Start().Format("15:04:05.000"),
+		Root:     root.Service + ": " + root.Operation,
+		Spans:    len(t.Spans),
+		Services: len(services),
+		Duration: spans.FormatDuration(t.Duration()),
+		Error:    t.HasError(),
+	}
+}
+
+type tracePage struct {
+	Summary *traceSummary
+	Rows    []*spanRow
+}
+
+// spanRow 瀑布图中的一行
+type spanRow struct {
+	Span     *spans.Span
+	Depth    int
+	Left     float64 // 相对 trace 开始的位置，百分比
+	Width    float64 // 百分比
+	Duration string
+	Offset   string
+	Tags     []keyValue
+	Logs     []logRow
+}
+
+type keyValue struct {
+	Key   string
+	Value string
+}
+
+type logRow struct {
+	Offset string
+	Fields []keyValue
+}
+
+func newTracePage(t *spans.Trace) *tracePage {
+	p := &tracePage{Summary: newTraceSummary(t)}
+	start, total := t.Start(), t.Duration()
+	var walk func(n *spans.Node, depth int)
+	walk = func(n *spans.Node, depth int) {
+		s := n.Span
+		row := &spanRow{
+			Span:     s,
+			Depth:    depth,
+			Left:     percent(s.Start.Sub(start), total),
+			Width:    percent(s.Duration, total),
+			Duration: spans.FormatDuration(s.Duration),
+			Offset:   spans.FormatDuration(s.Start.Sub(start)),
+			Tags:     keyValues(s.Tags),
+		}
+		if row.Left > 99.5 {
+			row.Left = 99.5
+		}
+		if row.Width < 0.5 {
+			row.Width = 0.5
+		}
+		for _, l := range s.Logs {
+			row.Logs = append(row.Logs, logRow{Offset: spans.FormatDuration(l.Timestamp.Sub(start)), Fields: keyValues(l.Fields)})
+		}
+		p.Rows = append(p.Rows, row)
+		for _, c := range n.Children {
+			walk(c, depth+1)
+		}
+	}
+	for _, root := range t.Tree() {
+		walk(root, 0)
+	}
+	return p
+}
+
+func percent(d, total time.Duration) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return float64(d) * 100 / float64(total)
+}
+
+func keyValues(m map[string]interface{}) []keyValue {
+	kvs := make([]keyValue, 0, len(m))
+	for k, v := range m {
+		kvs = append(kvs, keyValue{Key: k, Value: fmt.Sprint(v)})
+	}
+	sort.Slice(kvs, func(i, j int) bool { return kvs[i].Key < kvs[j].Key })
+	return kvs
+}
+
+const style = `<style>
+body { font-family: sans-serif; font-size: 13px; margin: 16px; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 3px 8px; border-bottom: 1px solid #eee; vertical-align: top; }
+.error { color: #c00; font-weight: bold; }
+form input, form select { margin-right: 8px; }
+.span summary { cursor: pointer; list-style: none; display: flex; }
+.label { width: 40%; overflow: hidden; white-space: nowrap; text-overflow: ellipsis; }
+.lane { width: 60%; position: relative; background: #f6f6f6; height: 16px; }
+.bar { position: absolute; top: 2px; height: 12px; background: #4a90d9; }
+.bar.err { background: #d94a4a; }
+.dur { position: absolute; right: 4px; font-size: 11px; }
+.detail { margin: 4px 0 8px 40%; }
+.detail td { font-family: monospace; }
+</style>`
+
+var listTemplate = template.Must(template.New("list").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>traces</title>` + style + `</head><body>
+<form method="get">
+<select name="service"><option value="">all services</option>
+{{range .Services}}<option{{if eq . $.Search.Service}} selected{{end}}>{{.}}</option>{{end}}
+</select>
+<input name="operation" placeholder="operation" value="{{.Search.Operation}}">
+<input name="min" placeholder="min duration, e.g. 100ms" value="{{.MinDuration}}">
+<input name="id" placeholder="trace id" value="{{.Search.TraceID}}">
+<label><input type="checkbox" name="errors" value="1"{{if .Search.Errors}} checked{{end}}>errors only</label>
+<input type="submit" value="search">
+</form>
+<p>{{len .Traces}} traces</p>
+<table>
+<tr><th>start</th><th>trace</th><th>root</th><th>spans</th><th>services</th><th>duration</th></tr>
+{{range .Traces}}<tr>
+<td>{{.Start}}</td>
+<td><a href="?trace={{.ID}}">{{.ID}}</a></td>
+<td{{if .Error}} class="error"{{end}}>{{.Root}}</td>
+<td>{{.Spans}}</td><td>{{.Services}}</td><td>{{.Duration}}</td>
+</tr>{{end}}
+</table>
+</body></html>`))
+
+var traceTemplate = template.Must(template.New("trace").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>trace {{.Summary.ID}}</title>` + style + `</head><body>
+<p><a href="?">&laquo; traces</a></p>
+<h3{{if .Summary.Error}} class="error"{{end}}>{{.Summary.Root}}</h3>
+<p>trace {{.Summary.ID}} &middot; {{.Summary.Start}} &middot; {{.Summary.Duration}} &middot; {{.Summary.Spans}} spans &middot; {{.Summary.Services}} services</p>
+{{range .Rows}}<details class="span">
+<summary>
+<span class="label{{if .Span.Error}} error{{end}}" style="padding-left: {{.Depth}}em">{{.Span.Service}}: {{.Span.Operation}}</span>
+<span class="lane"><span class="bar{{if .Span.Error}} err{{end}}" style="left: {{printf "%.2f" .Left}}%; width: {{printf "%.2f" .Width}}%"></span><span class="dur">{{.Duration}}</span></span>
+</summary>
+<div class="detail">
+<table>
+<tr><td>span id</td><td>{{.Span.SpanID}}</td></tr>
+{{if .Span.ParentID}}<tr><td>parent id</td><td>{{.Span.ParentID}}</td></tr>{{end}}
+<tr><td>start</td><td>+{{.Offset}}</td></tr>
+{{range .Span.References}}<tr><td>{{.Type}}</td><td>{{.SpanID}}</td></tr>{{end}}
+{{range .Tags}}<tr><td>{{.Key}}</td><td>{{.Value}}</td></tr>{{end}}
+{{range .Logs}}<tr><td>log +{{.Offset}}</td><td>{{range .Fields}}{{.Key}}={{.Value}}<br>{{end}}</td></tr>{{end}}
+</table>
+</div>
+</details>
+{{end}}
+</body></html>`))
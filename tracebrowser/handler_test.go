@@ -0,0 +1,82 @@
+package tracebrowser
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestRecorder() *Recorder {
+	r := NewRecorder(10)
+	r.WriteSpan(newSpan("aaa1", "1", "server1", "HTTP GET /test1", 0, 100))
+	r.WriteSpan(newSpan("aaa1", "2", "server2", "/proto.Echo/UnaryEcho", 10, 50))
+	slow := newSpan("bbb2", "1", "server1", "HTTP GET /test2", 200, 500)
+	slow.Tags = map[string]interface{}{"error": true}
+	r.WriteSpan(slow)
+	r.WriteSpan(newSpan("ccc3", "1", "server3", "SQL QUERY", 300, 5))
+	r.WriteSpan(newSpan("ccc3", "2", "server3", "SQL EXEC", 305, 5))
+	return r
+}
+
+func get(t *testing.T, h http.Handler, url string) (int, string) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+	return w.Code, w.Body.String()
+}
+
+func TestServeHTTPList(t *testing.T) {
+	r := newTestRecorder()
+	tests := []struct {
+		url  string
+		want []string // 列表中的 trace id
+	}{
+		{"/debug/traces", []string{"aaa1", "bbb2", "ccc3"}},
+		{"/debug/traces?service=server2", []string{"aaa1"}},
+		{"/debug/traces?operation=test2", []string{"bbb2"}},
+		{"/debug/traces?min=200ms", []string{"bbb2"}},
+		{"/debug/traces?errors=1", []string{"bbb2"}},
+		{"/debug/traces?id=ccc3", []string{"ccc3"}},
+		{"/debug/traces?service=server1&min=1s", nil},
+	}
+	for _, tt := range tests {
+		code, body := get(t, r, tt.url)
+		if code != http.StatusOK {
+			t.Errorf("%s: got status %d", tt.url, code)
+			continue
+		}
+		var got []string
+		for _, id := range []string{"aaa1", "bbb2", "ccc3"} {
+			if strings.Contains(body, `href="?trace=`+id+`"`) {
+				got = append(got, id)
+			}
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: got traces %v, want %v", tt.url, got, tt.want)
+		}
+	}
+	// 最新的 trace 在前
+	_, body := get(t, r, "/debug/traces")
+	if strings.Index(body, "?trace=ccc3") > strings.Index(body, "?trace=aaa1") {
+		t.Error("traces not listed newest first")
+	}
+}
+
+func TestServeHTTPTrace(t *testing.T) {
+	r := newTestRecorder()
+	code, body := get(t, r, "/debug/traces?trace=aaa1")
+	if code != http.StatusOK {
+		t.Fatalf("got status %d", code)
+	}
+	for _, want := range []string{"server1: HTTP GET /test1", "server2: /proto.Echo/UnaryEcho", "2 spans", "2 services"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("trace page does not contain %q", want)
+		}
+	}
+
+	code, body = get(t, r, "/debug/traces?trace=unknown")
+	if code != http.StatusNotFound || !strings.Contains(body, "trace not found: unknown") {
+		t.Errorf("got %d %q, want 404", code, body)
+	}
+}
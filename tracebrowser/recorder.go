@@ -0,0 +1,128 @@
+// Package tracebrowser 进程内的 trace 浏览器，类似 golang.org/x/net/trace 的 /debug/requests
+// 开发环境不用运行 jaeger ，也能查看最近的 trace
+//
+//	rec := tracebrowser.NewRecorder(100)
+//	tracer.Usejaeger(tracer.JaegerSink(rec))
+//	http.Handle("/debug/traces", rec)
+package tracebrowser
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/fananchong/tracer/spans"
+)
+
+// maxSpansPerTrace 每个 trace 最多保留的 span 数，避免个别 trace 占用过多内存
+const maxSpansPerTrace = 1000
+
+// Recorder 按服务名保留最近的 N 个 trace ，实现 spans.Sink 与 http.Handler
+type Recorder struct {
+	size int
+
+	mu       sync.Mutex
+	traces   map[string]*traceEntry
+	services map[string]*ring
+}
+
+type traceEntry struct {
+	spans    []*spans.Span
+	services map[string]bool // 引用该 trace 的服务
+}
+
+// ring 一个服务最近的 trace id
+type ring struct {
+	ids  []string
+	next int
+}
+
+// NewRecorder Recorder 构造函数
+// size 为每个服务保留的 trace 数
+func NewRecorder(size int) *Recorder {
+	if size <= 0 {
+		size = 100
+	}
+	return &Recorder{
+		size:     size,
+		traces:   make(map[string]*traceEntry),
+		services: make(map[string]*ring),
+	}
+}
+
+// WriteSpan 实现 spans.Sink
+func (r *Recorder) WriteSpan(span *spans.Span) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.traces[span.TraceID]
+	if !ok {
+		e = &traceEntry{services: make(map[string]bool)}
+		r.traces[span.TraceID] = e
+	}
+	if !e.services[span.Service] {
+		e.services[span.Service] = true
+		r.push(span.Service, span.TraceID)
+	}
+	if len(e.spans) < maxSpansPerTrace {
+		e.spans = append(e.spans, span)
+	}
+}
+
+// push 把 trace id 放入服务的环形队列，被挤出的 trace 不再被任何服务引用时删除
+func (r *Recorder) push(service, traceID string) {
+	rg, ok := r.services[service]
+	if !ok {
+		rg = &ring{}
+		r.services[service] = rg
+	}
+	if len(rg.ids) < r.size {
+		rg.ids = append(rg.ids, traceID)
+		return
+	}
+	old := rg.ids[rg.next]
+	rg.ids[rg.next] = traceID
+	rg.next = (rg.next + 1) % r.size
+	if e, ok := r.traces[old]; ok {
+		delete(e.services, service)
+		if len(e.services) == 0 {
+			delete(r.traces, old)
+		}
+	}
+}
+
+// Traces 获取保留的 trace ，按开始时间排序
+func (r *Recorder) Traces() []*spans.Trace {
+	r.mu.Lock()
+	var all []*spans.Span
+	for _, e := range r.traces {
+		all = append(all, e.spans...)
+	}
+	r.mu.Unlock()
+	return spans.Group(all)
+}
+
+// Trace 获取指定的 trace ，不存在时返回 nil
+func (r *Recorder) Trace(traceID string) *spans.Trace {
+	r.mu.Lock()
+	e, ok := r.traces[traceID]
+	var s []*spans.Span
+	if ok {
+		s = append(s, e.spans...)
+	}
+	r.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return spans.Group(s)[0]
+}
+
+// Services 获取服务名列表
+func (r *Recorder) Services() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, 0, len(r.services))
+	for name := range r.services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,96 @@
+package tracebrowser
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fananchong/tracer/spans"
+)
+
+var epoch = time.Unix(0, 0)
+
+// newSpan trace 的一个 span ， start 、 duration 为毫秒
+func newSpan(traceID, spanID, service, operation string, start, duration int) *spans.Span {
+	return &spans.Span{
+		TraceID:   traceID,
+		SpanID:    spanID,
+		Service:   service,
+		Operation: operation,
+		Start:     epoch.Add(time.Duration(start) * time.Millisecond),
+		Duration:  time.Duration(duration) * time.Millisecond,
+	}
+}
+
+func traceIDs(traces []*spans.Trace) []string {
+	ids := []string{}
+	for _, t := range traces {
+		ids = append(ids, t.ID)
+	}
+	return ids
+}
+
+// TestRecorderEviction 每个服务保留最近的 size 个 trace ，不再被任何服务引用的 trace 才删除
+func TestRecorderEviction(t *testing.T) {
+	r := NewRecorder(2)
+	r.WriteSpan(newSpan("t1", "1", "a", "op", 1, 1))
+	r.WriteSpan(newSpan("t2", "1", "a", "op", 2, 1))
+	r.WriteSpan(newSpan("t2", "2", "b", "op", 2, 1))
+	r.WriteSpan(newSpan("t3", "1", "a", "op", 3, 1))
+	if got := traceIDs(r.Traces()); !reflect.DeepEqual(got, []string{"t2", "t3"}) {
+		t.Fatalf("got %v, want t1 evicted", got)
+	}
+	// a 挤出 t2 ，但 b 还引用 t2
+	r.WriteSpan(newSpan("t4", "1", "a", "op", 4, 1))
+	if got := traceIDs(r.Traces()); !reflect.DeepEqual(got, []string{"t2", "t3", "t4"}) {
+		t.Fatalf("got %v, want t2 kept for service b", got)
+	}
+	if tr := r.Trace("t2"); tr == nil || len(tr.Spans) != 2 {
+		t.Fatalf("got %+v, want both spans of t2", tr)
+	}
+	// b 也挤出 t2
+	r.WriteSpan(newSpan("t5", "1", "b", "op", 5, 1))
+	r.WriteSpan(newSpan("t6", "1", "b", "op", 6, 1))
+	if got := traceIDs(r.Traces()); !reflect.DeepEqual(got, []string{"t3", "t4", "t5", "t6"}) {
+		t.Fatalf("got %v, want t2 evicted", got)
+	}
+	if r.Trace("t2") != nil {
+		t.Error("evicted trace still found")
+	}
+	if got := r.Services(); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("got services %v", got)
+	}
+}
+
+func TestRecorderLookup(t *testing.T) {
+	r := NewRecorder(10)
+	r.WriteSpan(newSpan("t2", "2", "a", "child", 25, 5))
+	r.WriteSpan(newSpan("t1", "1", "a", "root", 10, 10))
+	r.WriteSpan(newSpan("t2", "1", "a", "root", 20, 10))
+
+	if got := traceIDs(r.Traces()); !reflect.DeepEqual(got, []string{"t1", "t2"}) {
+		t.Errorf("got %v, want sorted by start time", got)
+	}
+	tr := r.Trace("t2")
+	if tr == nil || tr.ID != "t2" || len(tr.Spans) != 2 || tr.Spans[0].Operation != "root" {
+		t.Fatalf("got %+v", tr)
+	}
+	if r.Trace("missing") != nil {
+		t.Error("got a trace for an unknown id")
+	}
+	// 返回的是副本，之后写入的 span 不影响
+	r.WriteSpan(newSpan("t2", "3", "a", "late", 40, 1))
+	if len(tr.Spans) != 2 || len(r.Trace("t2").Spans) != 3 {
+		t.Errorf("got %d and %d spans", len(tr.Spans), len(r.Trace("t2").Spans))
+	}
+}
+
+func TestRecorderMaxSpans(t *testing.T) {
+	r := NewRecorder(1)
+	for i := 0; i < maxSpansPerTrace+10; i++ {
+		r.WriteSpan(newSpan("t1", "s", "a", "op", i, 1))
+	}
+	if n := len(r.Trace("t1").Spans); n != maxSpansPerTrace {
+		t.Errorf("got %d spans, want %d", n, maxSpansPerTrace)
+	}
+}
@@ -0,0 +1,50 @@
+package tracer
+
+import (
+	"context"
+	"strings"
+
+	"github.com/opentracing/opentracing-go"
+)
+
+// TraceID 获取 ctx 中 span 的 trace id ， ctx 中没有 span 时返回空字符串
+// jaeger 的 trace id 为 32 位十六进制，与 spans 、 tracecat 、 tracebrowser 中的相同
+// 可以返回给调用方（比如玩家报错时），方便在 tracer 后台查找对应的 trace
+func TraceID(ctx context.Context) string {
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil {
+		return ""
+	}
+	return traceIDOf(span.Tracer(), span.Context())
+}
+
+// traceIDGetter tracer 可选实现的接口，获取 span context 的 trace id
+type traceIDGetter interface {
+	TraceID(sc opentracing.SpanContext) string
+}
+
+// traceIDOf 获取 span context 的 trace id ， tracer 是创建该 span 的 tracer
+// 依次使用 tracer 、 DefaultTracer 实现的 traceIDGetter
+func traceIDOf(tracer opentracing.Tracer, sc opentracing.SpanContext) string {
+	for _, x := range []interface{}{tracer, DefaultTracer} {
+		if g, ok := x.(traceIDGetter); ok {
+			if id := g.TraceID(sc); id != "" {
+				return id
+			}
+		}
+	}
+	// 通用方法：注入到 TextMap 中，解析常见的格式
+	carrier := opentracing.TextMapCarrier{}
+	if err := tracer.Inject(sc, opentracing.TextMap, carrier); err != nil {
+		return ""
+	}
+	for k, v := range carrier {
+		switch strings.ToLower(k) {
+		case "uber-trace-id":
+			return strings.SplitN(v, ":", 2)[0]
+		case "x-b3-traceid", "mockpfx-ids-traceid":
+			return v
+		}
+	}
+	return ""
+}
@@ -2,6 +2,7 @@ package tracer
 
 import (
 	"github.com/fananchong/tracer/internal/jaeger"
+	"github.com/fananchong/tracer/spans"
 	"github.com/opentracing/opentracing-go"
 )
 
@@ -13,12 +14,16 @@ type ITracer interface {
 }
 
 // Enable 打开 tracer
+// 取消 EnableFor 设置的自动关闭
 func Enable(name string) (err error) {
+	cancelAutoDisable(name)
 	return DefaultTracer.Enable(name)
 }
 
 // Disable 关闭 tracer
+// 取消 EnableFor 设置的自动关闭
 func Disable(name string) {
+	cancelAutoDisable(name)
 	DefaultTracer.Disable(name)
 }
 
@@ -30,9 +35,43 @@ func Get(name string) opentracing.Tracer {
 // DefaultTracer tracer 具体实例
 var DefaultTracer ITracer
 
+// JaegerOption jaeger 选项
+type JaegerOption = jaeger.Option
+
+// TailSamplingConfig 尾部采样配置
+type TailSamplingConfig = jaeger.TailSamplingConfig
+
+// JaegerTailSampling 打开尾部采样
+// span 按 trace 缓存一段时间，保留含错误、慢、匹配 tag 的 trace ，其他 trace 按概率保留
+// 只对 Usejaeger 创建的 tracer 有效；每个进程只决定自己的 span ，详见 TailSamplingConfig
+func JaegerTailSampling(cfg TailSamplingConfig) JaegerOption {
+	return jaeger.WithTailSampling(cfg)
+}
+
+// JaegerSink 已结束的 span 同时写入 sink
+func JaegerSink(sink spans.Sink) JaegerOption {
+	return jaeger.WithSink(sink)
+}
+
+// JaegerWithoutAgent 不上报 jaeger agent ， span 只写入 sink
+func JaegerWithoutAgent() JaegerOption {
+	return jaeger.WithoutAgent()
+}
+
+// UseJSONLines 使用 jaeger 做为 tracer ， span 不上报 jaeger agent ，按 JSON lines 格式写入本地文件，用于离线分析
+// 文件超过 maxBytes 时滚动，最多保留 maxBackups 个旧文件
+func UseJSONLines(path string, maxBytes int64, maxBackups int, opts ...JaegerOption) (*spans.FileWriter, error) {
+	w, err := spans.NewFileWriter(path, maxBytes, maxBackups)
+	if err != nil {
+		return nil, err
+	}
+	Usejaeger(append([]JaegerOption{JaegerWithoutAgent(), JaegerSink(w)}, opts...)...)
+	return w, nil
+}
+
 // Usejaeger 使用 jaeger 做为 tracer
-func Usejaeger() {
-	DefaultTracer = jaeger.New()
+func Usejaeger(opts ...JaegerOption) {
+	DefaultTracer = jaeger.New(opts...)
 }
 
 func init() {
@@ -0,0 +1,89 @@
+package tracetest
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// UpdateEnv 环境变量不为空时， AssertGolden 用实际的 span 树覆盖 golden 文件，与 -update 参数相同：
+//
+//	go test ./tracetest -update
+//	UPDATE_GOLDEN=1 go test ./... # 参数会传给所有测试包，没有导入 tracetest 的包不认识 -update
+const UpdateEnv = "UPDATE_GOLDEN"
+
+// update -update 参数，导入 tracetest 的测试包不要再定义同名 flag
+var update = flag.Bool("update", false, "tracetest: update golden files")
+
+// updating 是否更新 golden 文件
+func updating() bool {
+	return *update || os.Getenv(UpdateEnv) != ""
+}
+
+// GoldenPath golden 文件路径
+func GoldenPath(name string) string {
+	return filepath.Join("testdata", name+".golden")
+}
+
+// AssertGolden 比较 got 与 golden 文件 testdata/<name>.golden ，不一致时测试失败
+// 指定 -update 参数或设置环境变量 UPDATE_GOLDEN 时，用 got 覆盖 golden 文件
+func AssertGolden(t testing.TB, name, got string) {
+	t.Helper()
+	path := GoldenPath(name)
+	if updating() {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("tracetest: %s", err.Error())
+		}
+		if err := ioutil.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatalf("tracetest: %s", err.Error())
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("tracetest: %s (run go test -update to create it)", err.Error())
+	}
+	if got != string(want) {
+		t.Errorf("tracetest: span tree does not match %s (run go test -update if the change is intended)\n%s", path, diff(string(want), got))
+	}
+}
+
+// diff 逐行比较，- 为 golden 文件中的行， + 为实际的行
+func diff(want, got string) string {
+	w, g := strings.Split(want, "\n"), strings.Split(got, "\n")
+	// 最长公共子序列
+	lcs := make([][]int, len(w)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(g)+1)
+	}
+	for i := len(w) - 1; i >= 0; i-- {
+		for j := len(g) - 1; j >= 0; j-- {
+			if w[i] == g[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var b strings.Builder
+	i, j := 0, 0
+	for i < len(w) || j < len(g) {
+		switch {
+		case i < len(w) && j < len(g) && w[i] == g[j]:
+			b.WriteString("  " + w[i] + "\n")
+			i, j = i+1, j+1
+		case i < len(w) && (j == len(g) || lcs[i+1][j] >= lcs[i][j+1]):
+			b.WriteString("- " + w[i] + "\n")
+			i++
+		default:
+			b.WriteString("+ " + g[j] + "\n")
+			j++
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,36 @@
+package tracetest
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// TestAssertGoldenUpdate -update 时写入 golden 文件，之后按文件比较
+func TestAssertGoldenUpdate(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	old := *update
+	defer func() { *update = old }()
+
+	*update = true
+	AssertGolden(t, "update", "a\n")
+	if got, err := ioutil.ReadFile(GoldenPath("update")); err != nil || string(got) != "a\n" {
+		t.Fatalf("got %q %v", got, err)
+	}
+	*update = false
+	AssertGolden(t, "update", "a\n")
+}
+
+func TestDiff(t *testing.T) {
+	got := diff("a\nb\nc", "a\nx\nc")
+	if want := "  a\n- b\n+ x\n  c\n"; got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,112 @@
+// Package tracetest 调用链的回归测试工具
+// 用内存中的 tracer 记录场景产生的 span ，把 span 树（忽略 id 、时间）与 testdata 下的 golden 文件比较，
+// 重构破坏了 EchoMiddleware 、 gRPC 拦截器等的 span context 传递时，测试失败
+//
+//	func TestEcho(t *testing.T) {
+//		rec := tracetest.Start(t, "server1", "server2")
+//		... 执行场景 ...
+//		rec.AssertGolden(t, "echo")
+//	}
+//
+// 调用链有意变化时，执行 go test -update （或 UPDATE_GOLDEN=1 go test ）更新 golden 文件
+package tracetest
+
+import (
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fananchong/tracer"
+	"github.com/fananchong/tracer/spans"
+)
+
+// Recorder 内存中记录已结束的 span ，实现 spans.Sink
+type Recorder struct {
+	mu    sync.Mutex
+	spans []*spans.Span
+}
+
+// Start 使用只记录到内存的 jaeger 做为 tracer （不上报 jaeger agent ），并打开 names 指定的 tracer
+// 测试结束时关闭创建的 tracer ，恢复原来的 tracer.DefaultTracer 与 names 的 tracer.Options
+// （测试中可以直接调用 tracer.SetOptions ）
+func Start(t testing.TB, names ...string) *Recorder {
+	t.Helper()
+	rec := &Recorder{}
+	old := tracer.DefaultTracer
+	oldOptions := make([]tracer.Options, len(names))
+	for i, name := range names {
+		oldOptions[i] = tracer.GetOptions(name)
+	}
+	tracer.Usejaeger(tracer.JaegerWithoutAgent(), tracer.JaegerSink(rec))
+	created := tracer.DefaultTracer
+	t.Cleanup(func() {
+		if c, ok := created.(io.Closer); ok {
+			c.Close()
+		}
+		tracer.DefaultTracer = old
+		for i, name := range names {
+			tracer.SetOptions(name, oldOptions[i])
+		}
+	})
+	for _, name := range names {
+		if err := tracer.Enable(name); err != nil {
+			t.Fatalf("tracetest: enable tracer %s: %s", name, err.Error())
+		}
+	}
+	return rec
+}
+
+// WriteSpan 实现 spans.Sink
+func (r *Recorder) WriteSpan(span *spans.Span) {
+	r.mu.Lock()
+	r.spans = append(r.spans, span)
+	r.mu.Unlock()
+}
+
+// Spans 获取记录的 span
+func (r *Recorder) Spans() []*spans.Span {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*spans.Span(nil), r.spans...)
+}
+
+// Wait 等待至少记录 n 个 span （ goroutine 中的 span 可能在场景返回后才结束），超时返回 false
+func (r *Recorder) Wait(n int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		r.mu.Lock()
+		count := len(r.spans)
+		r.mu.Unlock()
+		if count >= n {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Traces 获取记录的 trace ，按开始时间排序
+func (r *Recorder) Traces() []*spans.Trace {
+	return spans.Group(r.Spans())
+}
+
+// Reset 清空记录的 span
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	r.spans = nil
+	r.mu.Unlock()
+}
+
+// Tree 记录的 span 树，格式见 Tree
+func (r *Recorder) Tree(tags ...string) string {
+	return Tree(r.Traces(), tags...)
+}
+
+// AssertGolden 比较记录的 span 树与 golden 文件 testdata/<name>.golden
+func (r *Recorder) AssertGolden(t testing.TB, name string, tags ...string) {
+	t.Helper()
+	AssertGolden(t, name, r.Tree(tags...))
+}
@@ -0,0 +1,128 @@
+package tracetest_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fananchong/tracer"
+	pb "github.com/fananchong/tracer/examples/proto"
+	"github.com/fananchong/tracer/tracetest"
+	"github.com/labstack/echo/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type echoServer struct {
+	pb.UnimplementedEchoServer
+}
+
+func (echoServer) UnaryEcho(ctx context.Context, req *pb.EchoRequest) (*pb.EchoResponse, error) {
+	return &pb.EchoResponse{Message: req.Message}, nil
+}
+
+func (echoServer) ServerStreamingEcho(req *pb.EchoRequest, stream pb.Echo_ServerStreamingEchoServer) error {
+	for i := 0; i < 3; i++ {
+		if err := stream.Send(&pb.EchoResponse{Message: req.Message}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dialEcho 启动 gRPC 服务器（ tracer back ），返回客户端（ tracer front ）
+func dialEcho(t *testing.T) pb.EchoClient {
+	t.Helper()
+	l := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(
+		tracer.RPCUnaryServerInterceptorOption("back"),
+		tracer.RPCStreamServerInterceptorOption("back"),
+	)
+	pb.RegisterEchoServer(s, &echoServer{})
+	go s.Serve(l)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return l.Dial() }),
+		grpc.WithInsecure(),
+		tracer.RPCUnaryClientInterceptorOption("front"),
+		tracer.RPCStreamClientInterceptorOption("front"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewEchoClient(conn)
+}
+
+func TestEcho(t *testing.T) {
+	rec := tracetest.Start(t, "front")
+	e := echo.New()
+	e.Use(tracer.EchoMiddleware("front"))
+	e.GET("/ok", func(c echo.Context) error { return c.String(http.StatusOK, "ok") })
+	e.GET("/fail", func(c echo.Context) error { return echo.NewHTTPError(http.StatusInternalServerError, "fail") })
+	for _, path := range []string{"/ok", "/fail"} {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	rec.AssertGolden(t, "echo", "http.status_code")
+}
+
+func TestGRPCUnary(t *testing.T) {
+	rec := tracetest.Start(t, "front", "back")
+	client := dialEcho(t)
+	e := echo.New()
+	e.Use(tracer.EchoMiddleware("front"))
+	e.GET("/unary", func(c echo.Context) error {
+		resp, err := client.UnaryEcho(c.Request().Context(), &pb.EchoRequest{Message: "hello"})
+		if err != nil {
+			return err
+		}
+		return c.String(http.StatusOK, resp.Message)
+	})
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/unary", nil))
+	if !rec.Wait(3, time.Second) {
+		t.Fatalf("got %d spans, want 3", len(rec.Spans()))
+	}
+	rec.AssertGolden(t, "grpc_unary")
+}
+
+func TestGRPCStream(t *testing.T) {
+	rec := tracetest.Start(t, "front", "back")
+	client := dialEcho(t)
+	e := echo.New()
+	e.Use(tracer.EchoMiddleware("front"))
+	e.GET("/stream", func(c echo.Context) error {
+		stream, err := client.ServerStreamingEcho(c.Request().Context(), &pb.EchoRequest{Message: "hello"})
+		if err != nil {
+			return err
+		}
+		for {
+			if _, err := stream.Recv(); err == io.EOF {
+				break
+			} else if err != nil {
+				return err
+			}
+		}
+		return c.NoContent(http.StatusOK)
+	})
+	e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/stream", nil))
+	if !rec.Wait(3, time.Second) {
+		t.Fatalf("got %d spans, want 3", len(rec.Spans()))
+	}
+	rec.AssertGolden(t, "grpc_stream")
+}
+
+// TestStartRestoresOptions 测试结束后恢复 tracer.Options
+func TestStartRestoresOptions(t *testing.T) {
+	t.Run("set", func(t *testing.T) {
+		tracetest.Start(t, "front")
+		tracer.SetOptions("front", tracer.Options{Skip: []string{"/health"}})
+	})
+	if o := tracer.GetOptions("front"); len(o.Skip) != 0 {
+		t.Errorf("options not restored: %+v", o)
+	}
+}
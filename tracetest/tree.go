@@ -0,0 +1,82 @@
+package tracetest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fananchong/tracer/spans"
+)
+
+// Tree 把 trace 序列化为文本，忽略 id 、时间，用于与 golden 文件比较
+// 每个 span 一行，子 span 缩进两个空格：
+//
+//	server1: HTTP GET /test1 [kind=server component=HTTP]
+//	  server1: /proto.Echo/UnaryEcho [kind=client component=gRPC]
+//	    server2: /proto.Echo/UnaryEcho [kind=server component=gRPC error]
+//
+// 默认输出 span.kind 、 component 、 db.type 与 error ， tags 指定额外输出的 tag
+// 以 FollowsFrom 关联父 span 的，标记 follows_from
+// 兄弟 span 按文本排序，并发执行的子 span 输出稳定；trace 按开始时间排序，之间空一行
+func Tree(traces []*spans.Trace, tags ...string) string {
+	var blocks []string
+	for _, t := range traces {
+		blocks = append(blocks, strings.Join(sortedNodes(t.Tree(), tags), ""))
+	}
+	return strings.Join(blocks, "\n")
+}
+
+// sortedNodes 序列化节点及其子树，兄弟节点按文本排序
+func sortedNodes(nodes []*spans.Node, tags []string) []string {
+	result := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		var b strings.Builder
+		b.WriteString(spanLine(n.Span, tags))
+		b.WriteString("\n")
+		for _, c := range sortedNodes(n.Children, tags) {
+			b.WriteString(indent(c))
+		}
+		result = append(result, b.String())
+	}
+	sort.Strings(result)
+	return result
+}
+
+func spanLine(s *spans.Span, tags []string) string {
+	var attrs []string
+	for _, key := range []string{"span.kind", "component", "db.type"} {
+		if v := s.Tag(key); v != "" {
+			attrs = append(attrs, strings.TrimPrefix(key, "span.")+"="+v)
+		}
+	}
+	for _, key := range tags {
+		if v, ok := s.Tags[key]; ok {
+			attrs = append(attrs, key+"="+fmt.Sprint(v))
+		}
+	}
+	if s.Error() {
+		attrs = append(attrs, "error")
+	}
+	parent := spans.ParentOf(s)
+	for _, ref := range s.References {
+		if ref.SpanID == parent && ref.Type == "follows_from" {
+			attrs = append(attrs, "follows_from")
+			break
+		}
+	}
+	line := s.Service + ": " + s.Operation
+	if len(attrs) > 0 {
+		line += " [" + strings.Join(attrs, " ") + "]"
+	}
+	return line
+}
+
+func indent(text string) string {
+	lines := strings.SplitAfter(text, "\n")
+	for i, l := range lines {
+		if l != "" {
+			lines[i] = "  " + l
+		}
+	}
+	return strings.Join(lines, "")
+}
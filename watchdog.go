@@ -0,0 +1,190 @@
+package tracer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/log"
+	"google.golang.org/grpc/grpclog"
+)
+
+// WatchdogOptions 慢 span 现场采集选项
+// 服务器端 span 执行超过阈值（还没结束）时，采集 goroutine dump （可选 CPU profile ），文件路径记录在 span 上
+type WatchdogOptions struct {
+	// Threshold 服务器端 span 执行超过该耗时时采集。 0 表示不检查
+	Threshold time.Duration
+	// Dir 采集文件保存目录，为空时使用 os.TempDir()
+	Dir string
+	// CPUProfile 大于 0 时，同时采集这么长时间的 CPU profile （在后台进行， span 结束不影响采集）
+	CPUProfile time.Duration
+	// MinInterval 同一 tracer 两次采集的最小间隔，避免频繁采集拖垮进程。 0 表示 1 分钟
+	MinInterval time.Duration
+	// ForceSample 采集过现场的 span 强制采样，避免被采样器丢弃
+	ForceSample bool
+}
+
+// defaultWatchdogInterval 默认的两次采集最小间隔
+const defaultWatchdogInterval = time.Minute
+
+// watchdog 采集限流，按 tracer 名字记录最近一次采集的时间
+var watchdog struct {
+	sync.Mutex
+	last map[string]time.Time
+}
+
+// cpuProfile 记录 CPU profile 是否由 watchdog 启动，只停止自己启动的
+var cpuProfile struct {
+	sync.Mutex
+	owned bool
+}
+
+// allowCapture 距离 tracer 上次采集超过 interval 时返回 true ，并记录本次采集时间
+func allowCapture(tracerName string, now time.Time, interval time.Duration) bool {
+	watchdog.Lock()
+	defer watchdog.Unlock()
+	if last, ok := watchdog.last[tracerName]; ok && now.Sub(last) < interval {
+		return false
+	}
+	if watchdog.last == nil {
+		watchdog.last = map[string]time.Time{}
+	}
+	watchdog.last[tracerName] = now
+	return true
+}
+
+// watchSpan 服务器端 span 开始时调用，返回的函数在 span 结束前调用， tags 为开始 span 时设置的 tag ，强制采样时重新设置
+// span 执行超过 Watchdog.Threshold 时采集现场；返回的函数会等待正在进行的 goroutine dump 完成，确保 span 结束前记录了文件路径
+func watchSpan(tracerName string, span opentracing.Span, tags spanTags) (stop func()) {
+	o := getOptions(tracerName).Watchdog
+	if o.Threshold <= 0 {
+		return func() {}
+	}
+	start := time.Now()
+	done := make(chan struct{})
+	timer := time.AfterFunc(o.Threshold, func() {
+		defer close(done)
+		captureSpan(tracerName, span, tags, &o, time.Since(start))
+	})
+	return func() {
+		if !timer.Stop() {
+			<-done
+		}
+	}
+}
+
+// captureSpan 采集现场，记录到 span
+func captureSpan(tracerName string, span opentracing.Span, tags spanTags, o *WatchdogOptions, elapsed time.Duration) {
+	interval := o.MinInterval
+	if interval <= 0 {
+		interval = defaultWatchdogInterval
+	}
+	now := time.Now()
+	if !allowCapture(tracerName, now, interval) {
+		span.LogFields(log.String("event", "watchdog"), log.String("elapsed", elapsed.String()), log.String("skipped", "rate limited"))
+		return
+	}
+	// 未采样的 span 会丢弃 tag 、 log ，先设置采样优先级，再重新设置开始时的 tag
+	if o.ForceSample {
+		forceSample(span, tags)
+	}
+
+	dir := o.Dir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	fields := []log.Field{log.String("event", "watchdog"), log.String("elapsed", elapsed.String())}
+	prefix, err := capturePrefix(dir, tracerName, span, now)
+	if err == nil {
+		err = writeGoroutineDump(prefix + "-goroutine.txt")
+	}
+	if err != nil {
+		grpclog.Errorf("watchdog: cannot capture %s: %s", tracerName, err.Error())
+		span.LogFields(append(fields, log.Error(err))...)
+		return
+	}
+	fields = append(fields, log.String("goroutine.dump", prefix+"-goroutine.txt"))
+	if o.CPUProfile > 0 {
+		if err = startCPUProfile(prefix+"-cpu.pprof", o.CPUProfile); err != nil {
+			grpclog.Warningf("watchdog: %s", err.Error())
+			fields = append(fields, log.String("cpu.profile.error", err.Error()))
+		} else {
+			fields = append(fields, log.String("cpu.profile", prefix+"-cpu.pprof"))
+		}
+	}
+	span.SetTag("watchdog.captured", true)
+	span.LogFields(fields...)
+}
+
+// capturePrefix 采集文件的路径前缀：目录/服务名-时间-trace id
+func capturePrefix(dir, tracerName string, span opentracing.Span, now time.Time) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	name := sanitizeFileName(tracerName) + "-" + now.Format("20060102-150405.000")
+	if id := traceIDOf(span.Tracer(), span.Context()); id != "" {
+		name += "-" + id
+	}
+	return filepath.Join(dir, name), nil
+}
+
+func sanitizeFileName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r == ' ' {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// writeGoroutineDump 写入所有 goroutine 的调用栈（与 panic 时的格式相同）
+func writeGoroutineDump(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = pprof.Lookup("goroutine").WriteTo(f, 2); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// startCPUProfile 在后台采集 d 时间的 CPU profile
+// 同时只能有一个 CPU profile ，其他地方（比如 /debug/pprof/profile ）正在采集时返回错误，也不会停止其他地方的采集
+func startCPUProfile(path string, d time.Duration) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	cpuProfile.Lock()
+	defer cpuProfile.Unlock()
+	if err = pprof.StartCPUProfile(f); err != nil {
+		f.Close()
+		os.Remove(path)
+		return errors.New("start cpu profile: " + err.Error())
+	}
+	cpuProfile.owned = true
+	time.AfterFunc(d, func() {
+		stopCPUProfile()
+		if err := f.Close(); err != nil {
+			grpclog.Errorf("watchdog: cannot write cpu profile %s: %s", path, err.Error())
+		}
+	})
+	return nil
+}
+
+// stopCPUProfile 停止 watchdog 启动的 CPU profile
+func stopCPUProfile() {
+	cpuProfile.Lock()
+	defer cpuProfile.Unlock()
+	if cpuProfile.owned {
+		pprof.StopCPUProfile()
+		cpuProfile.owned = false
+	}
+}
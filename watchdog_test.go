@@ -0,0 +1,96 @@
+package tracer_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime/pprof"
+	"testing"
+	"time"
+
+	"github.com/fananchong/tracer"
+	"github.com/fananchong/tracer/tracetest"
+)
+
+// serveSlow 用 HTTPMiddleware 处理一个耗时 d 的请求
+func serveSlow(tracerName string, d time.Duration) {
+	h := tracer.HTTPMiddleware(tracerName, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(d)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil))
+}
+
+// TestWatchdogForceSample 采样率为 0 时，强制采样的 span 也带上采集的 tag 与开始时的 tag
+func TestWatchdogForceSample(t *testing.T) {
+	rec := tracetest.Start(t, "watchdog1")
+	tracer.SetOptions("watchdog1", tracer.Options{Watchdog: tracer.WatchdogOptions{
+		Threshold: 10 * time.Millisecond, Dir: t.TempDir(), ForceSample: true,
+	}})
+	if err := tracer.SetSamplingRate("watchdog1", 0); err != nil {
+		t.Fatal(err)
+	}
+	serveSlow("watchdog1", 50*time.Millisecond)
+	got := rec.Spans()
+	if len(got) != 1 {
+		t.Fatalf("got %d spans, want 1 (captured span should be force sampled)", len(got))
+	}
+	if got[0].Tag("watchdog.captured") != "true" {
+		t.Errorf("got tags %v", got[0].Tags)
+	}
+	// 开始时设置的 tag 没有丢失，能看出是哪个请求
+	for key, want := range map[string]string{
+		"component":   "HTTP",
+		"span.kind":   "server",
+		"http.method": "GET",
+		"http.url":    "/slow",
+	} {
+		if got := got[0].Tag(key); got != want {
+			t.Errorf("got %s=%q, want %q", key, got, want)
+		}
+	}
+}
+
+// TestWatchdogRateLimit 限流按 tracer 计算，一个 tracer 的采集不影响其他 tracer
+func TestWatchdogRateLimit(t *testing.T) {
+	rec := tracetest.Start(t, "watchdog2", "watchdog3")
+	o := tracer.Options{Watchdog: tracer.WatchdogOptions{Threshold: 10 * time.Millisecond, Dir: t.TempDir()}}
+	tracer.SetOptions("watchdog2", o)
+	tracer.SetOptions("watchdog3", o)
+	serveSlow("watchdog2", 30*time.Millisecond)
+	serveSlow("watchdog2", 30*time.Millisecond)
+	serveSlow("watchdog3", 30*time.Millisecond)
+	var captured int
+	for _, s := range rec.Spans() {
+		if s.Tag("watchdog.captured") == "true" {
+			captured++
+		}
+	}
+	if captured != 2 {
+		t.Errorf("got %d captured spans, want 2", captured)
+	}
+}
+
+// TestWatchdogCPUProfileOwner 其他地方正在采集 CPU profile 时，不会被 watchdog 停止
+func TestWatchdogCPUProfileOwner(t *testing.T) {
+	var buf bytes.Buffer
+	if err := pprof.StartCPUProfile(&buf); err != nil {
+		t.Skip("cpu profile already running:", err)
+	}
+	defer pprof.StopCPUProfile()
+
+	rec := tracetest.Start(t, "watchdog4")
+	tracer.SetOptions("watchdog4", tracer.Options{Watchdog: tracer.WatchdogOptions{
+		Threshold: 10 * time.Millisecond, Dir: t.TempDir(), CPUProfile: 10 * time.Millisecond,
+	}})
+	serveSlow("watchdog4", 30*time.Millisecond)
+	got := rec.Spans()
+	if len(got) != 1 || got[0].Tag("watchdog.captured") != "true" {
+		t.Fatalf("got %+v", got)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := pprof.StartCPUProfile(os.Stderr); err == nil {
+		pprof.StopCPUProfile()
+		t.Fatal("watchdog stopped a cpu profile it did not start")
+	}
+}